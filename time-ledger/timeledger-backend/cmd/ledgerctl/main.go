@@ -0,0 +1,164 @@
+/*
+ledgerctl
+---------
+运维命令行：
+
+	ledgerctl [-config configs/config.toml] rewind  -chain N -contract 0x.. -block B [-dry-run]
+	ledgerctl [-config configs/config.toml] reindex -chain N -contract 0x.. [-dry-run]
+	ledgerctl [-config configs/config.toml] recompute -chain N -contract 0x.. [-from 2006-01-02T15:04:05Z]
+
+rewind / reindex 需要占用合约的 indexer 租约：执行前先停止负责该合约的 indexer（或等待其租约过期），
+否则返回 "contract is being indexed"；不停服时可改用运行中服务的 POST /admin/contracts/rewind。-dry-run 不受限制。
+
+数据库连接读取 DB_* 环境变量；pending 区块存储需与 indexer 一致：
+设置 REDIS_ADDR 时清理 Redis 中的 pending 区块（REDIS_PASSWORD / REDIS_DB 可选），否则清理 pending_block 表
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/config"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/calculator"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/indexer"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ledgerctl [-config path] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  rewind   -chain N -contract 0x.. -block B [-dry-run]   回退合约到指定区块")
+	fmt.Fprintln(os.Stderr, "  reindex  -chain N -contract 0x.. [-dry-run]            从 start_block 重新索引")
+	fmt.Fprintln(os.Stderr, "  recompute -chain N -contract 0x.. [-from RFC3339]      执行积分回溯重算任务（-from 手工创建）")
+}
+
+func main() {
+	configPath := flag.String("config", "configs/config.toml", "config file path")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, *configPath, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, configPath, cmd string, args []string) error {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	chainID := fs.Int64("chain", 0, "chain id")
+	contract := fs.String("contract", "", "contract address")
+	dryRun := fs.Bool("dry-run", false, "only report affected rows")
+
+	var (
+		block *int64
+		from  *string
+	)
+	switch cmd {
+	case "rewind":
+		block = fs.Int64("block", -1, "target block (data <= block is kept)")
+	case "reindex":
+	case "recompute":
+		from = fs.String("from", "", "recompute points issued after this time (RFC3339)")
+	default:
+		usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *chainID == 0 || *contract == "" {
+		return fmt.Errorf("-chain and -contract are required")
+	}
+	if block != nil && *block < 0 {
+		return fmt.Errorf("-block is required")
+	}
+
+	cfg, db, err := loadDB(configPath)
+	if err != nil {
+		return err
+	}
+
+	var res any
+	switch cmd {
+	case "recompute":
+		var at time.Time
+		if *from != "" {
+			if at, err = time.Parse(time.RFC3339, *from); err != nil {
+				return fmt.Errorf("invalid -from: %w", err)
+			}
+		}
+		res, err = calculator.New(db, cfg).Recompute(ctx, *chainID, *contract, at)
+
+	case "rewind", "reindex":
+		var ix *indexer.Indexer
+		if ix, err = newIndexer(cfg, db); err != nil {
+			return err
+		}
+		if cmd == "rewind" {
+			res, err = ix.Rewind(ctx, *chainID, *contract, *block, *dryRun)
+		} else {
+			res, err = ix.Reindex(ctx, *chainID, *contract, *dryRun)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+func loadDB(configPath string) (*config.Config, *gorm.DB, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config: %w", err)
+	}
+
+	db, err := repository.InitDB(cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init db: %w", err)
+	}
+	return cfg, db, nil
+}
+
+func newIndexer(cfg *config.Config, db *gorm.DB) (*indexer.Indexer, error) {
+	var (
+		rdb *redis.Client
+		err error
+	)
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		redisDB := 0
+		if v := os.Getenv("REDIS_DB"); v != "" {
+			if redisDB, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid REDIS_DB: %w", err)
+			}
+		}
+		if rdb, err = repository.InitRedis(addr, os.Getenv("REDIS_PASSWORD"), redisDB); err != nil {
+			return nil, fmt.Errorf("init redis: %w", err)
+		}
+	}
+
+	return indexer.New(db, cfg, rdb), nil
+}
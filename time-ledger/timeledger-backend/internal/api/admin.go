@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/indexer"
+)
+
+/*
+Admin
+-----
+- 运维接口，默认不注册；调用 EnableAdmin 且配置了 token 才开放
+- 请求需携带 X-Admin-Token 头
+*/
+
+// ContractRewinder 合约回退 / 重建（由 indexer.Indexer 实现）
+type ContractRewinder interface {
+	Rewind(ctx context.Context, chainID int64, contract string, target int64, dryRun bool) (indexer.RewindResult, error)
+	Reindex(ctx context.Context, chainID int64, contract string, dryRun bool) (indexer.RewindResult, error)
+}
+
+// AdminDeps 运维接口依赖
+type AdminDeps struct {
+	Token     string
+	Contracts ContractRewinder
+}
+
+// EnableAdmin 开启运维接口（需在 Register 之前调用）
+func (s *Server) EnableAdmin(deps AdminDeps) {
+	s.admin = &deps
+}
+
+func (s *Server) registerAdmin(r *gin.Engine) {
+	if s.admin == nil || s.admin.Token == "" {
+		return
+	}
+
+	g := r.Group("/admin", s.adminAuth)
+	g.POST("/contracts/rewind", s.RewindContract)
+	g.POST("/contracts/reindex", s.ReindexContract)
+
+	g.GET("/rates", s.ListRates)
+	g.POST("/rates", s.AddRate)
+	g.DELETE("/rates/:id", s.CancelRate)
+
+	g.GET("/rate_formulas", s.ListRateFormulas)
+	g.POST("/rate_formulas", s.AddRateFormula)
+
+	g.GET("/boosts", s.ListBoosts)
+	g.POST("/boosts", s.AddBoost)
+	g.DELETE("/boosts/:id", s.CancelBoost)
+
+	// expvar 指标（reorg 次数等），包含进程内部信息，不公开
+	g.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+}
+
+// adminAuth 校验 X-Admin-Token
+func (s *Server) adminAuth(c *gin.Context) {
+	token := c.GetHeader("X-Admin-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.admin.Token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.Next()
+}
+
+type rewindReq struct {
+	ChainID  int64  `json:"chain_id"`
+	Contract string `json:"contract"`
+	Block    int64  `json:"block"`
+	DryRun   bool   `json:"dry_run"`
+}
+
+func bindRewindReq(c *gin.Context) (rewindReq, bool) {
+	var req rewindReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return req, false
+	}
+	if req.ChainID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+		return req, false
+	}
+	if req.Contract == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing contract"})
+		return req, false
+	}
+	return req, true
+}
+
+func writeRewindResult(c *gin.Context, res indexer.RewindResult, err error) {
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, indexer.ErrContractBusy) || errors.Is(err, indexer.ErrCalculatorBusy) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// POST /admin/contracts/rewind {chain_id, contract, block, dry_run}
+func (s *Server) RewindContract(c *gin.Context) {
+	req, ok := bindRewindReq(c)
+	if !ok {
+		return
+	}
+	if req.Block < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid block"})
+		return
+	}
+
+	res, err := s.admin.Contracts.Rewind(c.Request.Context(), req.ChainID, req.Contract, req.Block, req.DryRun)
+	writeRewindResult(c, res, err)
+}
+
+// POST /admin/contracts/reindex {chain_id, contract, dry_run}
+func (s *Server) ReindexContract(c *gin.Context) {
+	req, ok := bindRewindReq(c)
+	if !ok {
+		return
+	}
+
+	res, err := s.admin.Contracts.Reindex(c.Request.Context(), req.ChainID, req.Contract, req.DryRun)
+	writeRewindResult(c, res, err)
+}
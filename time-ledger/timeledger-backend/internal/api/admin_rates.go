@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/rates"
+)
+
+// GET /admin/rates?chain_id=&contract=
+// 合约完整积分规则时间表（含未生效的规则），按生效时间升序
+func (s *Server) ListRates(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	rows, err := rates.New(s.db).List(c.Request.Context(), chainID, contract)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+type addRateReq struct {
+	ChainID         int64     `json:"chain_id"`
+	Contract        string    `json:"contract"`
+	RateNumerator   int64     `json:"rate_numerator"`
+	RateDenominator int64     `json:"rate_denominator"`
+	EffectiveTime   time.Time `json:"effective_time"`
+	FormulaID       uint64    `json:"formula_id"`
+	Retroactive     bool      `json:"retroactive"`
+}
+
+// POST /admin/rates {chain_id, contract, rate_numerator, rate_denominator, effective_time, formula_id, retroactive}
+// 新增积分规则；effective_time 早于当前时间需 retroactive = true，并触发积分回溯重算
+func (s *Server) AddRate(c *gin.Context) {
+	var req addRateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if req.ChainID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+		return
+	}
+	if req.Contract == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing contract"})
+		return
+	}
+
+	rate, err := rates.New(s.db).Add(c.Request.Context(), rates.NewRate{
+		ChainID:         req.ChainID,
+		Contract:        req.Contract,
+		RateNumerator:   req.RateNumerator,
+		RateDenominator: req.RateDenominator,
+		EffectiveTime:   req.EffectiveTime,
+		FormulaID:       req.FormulaID,
+		Retroactive:     req.Retroactive,
+	})
+	if err != nil {
+		writeRateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// DELETE /admin/rates/:id
+// 取消尚未生效的积分规则
+func (s *Server) CancelRate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	rate, err := rates.New(s.db).Cancel(c.Request.Context(), id)
+	if err != nil {
+		writeRateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
+
+// GET /admin/rate_formulas?chain_id=&contract=
+// 合约的全部公式版本
+func (s *Server) ListRateFormulas(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	rows, err := rates.New(s.db).ListFormulas(c.Request.Context(), chainID, contract)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+type addFormulaReq struct {
+	ChainID    int64           `json:"chain_id"`
+	Contract   string          `json:"contract"`
+	Name       string          `json:"name"`
+	Definition json.RawMessage `json:"definition"`
+}
+
+// POST /admin/rate_formulas {chain_id, contract, name, definition}
+// 追加公式新版本，返回的 id 用于 POST /admin/rates 的 formula_id
+func (s *Server) AddRateFormula(c *gin.Context) {
+	var req addFormulaReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if req.ChainID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+		return
+	}
+	if req.Contract == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing contract"})
+		return
+	}
+
+	row, err := rates.New(s.db).AddFormula(c.Request.Context(), req.ChainID, req.Contract, req.Name, req.Definition)
+	if err != nil {
+		writeRateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, row)
+}
+
+// GET /admin/boosts?chain_id=&contract=&account=
+// 合约的账户倍率，account 可选
+func (s *Server) ListBoosts(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	rows, err := rates.New(s.db).ListBoosts(c.Request.Context(), chainID, contract, c.Query("account"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+type addBoostReq struct {
+	ChainID     int64           `json:"chain_id"`
+	Contract    string          `json:"contract"`
+	Account     string          `json:"account"`
+	Multiplier  decimal.Decimal `json:"multiplier"`
+	StartTime   time.Time       `json:"start_time"`
+	EndTime     *time.Time      `json:"end_time"`
+	Reason      string          `json:"reason"`
+	Retroactive bool            `json:"retroactive"`
+}
+
+// POST /admin/boosts {chain_id, contract, account, multiplier, start_time, end_time, reason, retroactive}
+// 新增账户倍率；start_time 早于当前时间需 retroactive = true，并触发积分回溯重算
+func (s *Server) AddBoost(c *gin.Context) {
+	var req addBoostReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if req.ChainID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+		return
+	}
+	if req.Contract == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing contract"})
+		return
+	}
+
+	boost, err := rates.New(s.db).AddBoost(c.Request.Context(), rates.NewBoost{
+		ChainID:     req.ChainID,
+		Contract:    req.Contract,
+		Account:     req.Account,
+		Multiplier:  req.Multiplier,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Reason:      req.Reason,
+		Retroactive: req.Retroactive,
+	})
+	if err != nil {
+		writeRateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, boost)
+}
+
+// DELETE /admin/boosts/:id
+// 取消尚未开始的账户倍率
+func (s *Server) CancelBoost(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	boost, err := rates.New(s.db).CancelBoost(c.Request.Context(), id)
+	if err != nil {
+		writeRateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, boost)
+}
+
+func writeRateError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, rates.ErrInvalidRate),
+		errors.Is(err, rates.ErrBackdated),
+		errors.Is(err, rates.ErrInvalidFormula),
+		errors.Is(err, rates.ErrInvalidBoost):
+		status = http.StatusBadRequest
+	case errors.Is(err, rates.ErrContractNotFound),
+		errors.Is(err, rates.ErrRateNotFound),
+		errors.Is(err, rates.ErrFormulaNotFound),
+		errors.Is(err, rates.ErrBoostNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, rates.ErrDuplicateTime),
+		errors.Is(err, rates.ErrAlreadyEffective),
+		errors.Is(err, rates.ErrInitialRateLocked),
+		errors.Is(err, rates.ErrBoostStarted):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /indexer/anomalies?chain_id=&contract=&account=&kind=&limit=&offset=
+// 按时间倒序返回索引异常（负余额等），用于人工排查被隔离的账户
+func (s *Server) GetAnomalies(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePage(c)
+
+	q := s.db.
+		Model(&models.IndexerAnomaly{}).
+		Where("chain_id=? AND contract_address=?", chainID, contract)
+
+	if account := c.Query("account"); account != "" {
+		q = q.Where("account=?", account)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		q = q.Where("kind=?", kind)
+	}
+
+	var anomalies []models.IndexerAnomaly
+	if err := q.
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&anomalies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, anomalies)
+}
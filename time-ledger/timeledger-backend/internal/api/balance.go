@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /user/balance?chain_id=&contract=&account=&view=
+// balance 为链上原始整数，balance_display 按合约 decimals 换算
+// view=latest 时额外返回 pending 区块叠加后的余额（confirmed=false）
+func (s *Server) GetUserBalance(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	account := c.Query("account")
+	if account == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing account"})
+		return
+	}
+
+	latest, ok := s.parseView(c)
+	if !ok {
+		return
+	}
+
+	var sysC models.SysContract
+	if err := s.db.Where("chain_id = ? AND address = ?", chainID, contract).First(&sysC).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contract not configured"})
+		return
+	}
+
+	decimals := sysC.BalanceDecimals()
+
+	var ub models.UserBalance
+	err := s.db.
+		Where(
+			"chain_id=? AND contract_address=? AND account=?",
+			chainID, contract, account,
+		).
+		First(&ub).Error
+
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found && !latest {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user balance not found"})
+		return
+	}
+
+	resp := gin.H{
+		"chain_id":         chainID,
+		"contract_address": contract,
+		"account":          account,
+		"decimals":         decimals,
+	}
+
+	// 已确认部分：latest 视图下账户可能只出现在 pending 中，此时从 0 开始
+	base := big.NewInt(0)
+	if found {
+		display, err := displayAmount(ub.Balance, decimals)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := base.SetString(ub.Balance, 10); !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid balance in db"})
+			return
+		}
+
+		resp["account"] = ub.Account
+		resp["balance"] = ub.Balance
+		resp["balance_display"] = display
+		resp["block_number"] = ub.BlockNumber
+		resp["block_time"] = ub.BlockTime
+	}
+
+	if !latest {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	changes, ok, err := s.loadPendingChanges(c.Request.Context(), chainID, contract, account, base)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		// pending 数据不可用：退回已确认视图
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user balance not found"})
+			return
+		}
+		resp["view"] = viewFinalized
+		resp["notice"] = pendingUnavailable
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if !found && len(changes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user balance not found"})
+		return
+	}
+
+	latestBal := base
+	if len(changes) > 0 {
+		latestBal = changes[len(changes)-1].BalanceAfter
+	}
+	latestDisplay, err := displayAmount(latestBal.String(), decimals)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	items, err := pendingChangesJSON(changes, decimals)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp["view"] = viewLatest
+	resp["pending"] = gin.H{
+		"confirmed":       false,
+		"balance":         latestBal.String(),
+		"balance_display": latestDisplay,
+		"changes":         items,
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// displayAmount 原始整数按 decimals 换算为展示值
+func displayAmount(raw string, decimals int32) (string, error) {
+	d, err := decimal.NewFromString(raw)
+	if err != nil {
+		return "", err
+	}
+	return d.Shift(-decimals).String(), nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /calculator/runs?chain_id=&contract=&status=&limit=&offset=
+// 按开始时间倒序返回积分计算记录；chain_id / contract / status 可选
+func (s *Server) GetCalcRuns(c *gin.Context) {
+	q := s.db.Model(&models.CalcRun{})
+
+	if v := c.Query("chain_id"); v != "" {
+		chainID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || chainID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+			return
+		}
+		q = q.Where("chain_id=?", chainID)
+	}
+	if contract := c.Query("contract"); contract != "" {
+		q = q.Where("contract_address=?", contract)
+	}
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case models.CalcRunRunning, models.CalcRunSuccess, models.CalcRunPartial, models.CalcRunFailed:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+			return
+		}
+		q = q.Where("status=?", status)
+	}
+
+	limit, offset := parsePage(c)
+
+	var rows []models.CalcRun
+	if err := q.
+		Order("started_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /contract/events?chain_id=&contract=&event=&topic1=&topic2=&topic3=&tx_hash=&from_block=&to_block=&limit=&offset=
+// - event：事件名（如 Deposit）
+// - topic1~3：indexed 参数的 32 字节原始值（地址需左侧补零到 32 字节）
+func (s *Server) GetContractEvents(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePage(c)
+
+	q := s.db.
+		Model(&models.ContractEvent{}).
+		Where("chain_id=? AND contract_address=?", chainID, contract)
+
+	if v := c.Query("event"); v != "" {
+		q = q.Where("event_name=?", v)
+	}
+	for _, col := range []string{"topic1", "topic2", "topic3"} {
+		if v := c.Query(col); v != "" {
+			q = q.Where(col+"=?", v)
+		}
+	}
+	if v := c.Query("tx_hash"); v != "" {
+		q = q.Where("tx_hash=?", v)
+	}
+	if v := c.Query("from_block"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			q = q.Where("block_number >= ?", n)
+		}
+	}
+	if v := c.Query("to_block"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			q = q.Where("block_number <= ?", n)
+		}
+	}
+
+	var rows []models.ContractEvent
+	if err := q.
+		Order("block_number DESC, log_index DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
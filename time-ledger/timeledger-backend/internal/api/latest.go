@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/calculator"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/indexer"
+)
+
+/*
+Latest View
+-----------
+- 余额 / 积分接口默认只返回已确认（finalized）数据
+- ?view=latest 时在已确认数据之上叠加 OP Stack pending 区块中的 Transfer，
+  叠加部分放在 pending 字段中并标记 confirmed=false
+- 积分的 latest 估算 = 已结算积分 + [last_calc_time, now) 按已确认余额与 pending 余额累计的积分，
+  与 calculator 使用同一套分段算法，但不落库；还没有 user_point 的账户（只出现在 pending 中，
+  或尚未被计算过）从首次出现的时间开始估算，与余额接口一致
+- pending 数据只读（PendingStore.ListAfter），读取失败或已过期时退回已确认视图（view=finalized）
+- 需调用 EnableLatestView 注入与 indexer 相同的 PendingStore
+*/
+
+const (
+	viewFinalized = "finalized"
+	viewLatest    = "latest"
+)
+
+// EnableLatestView 开启 ?view=latest（需在处理请求前调用）
+func (s *Server) EnableLatestView(store indexer.PendingStore) {
+	s.pending = store
+}
+
+// parseView 解析 view 参数，返回是否为 latest；失败时已写好 400 响应
+func (s *Server) parseView(c *gin.Context) (bool, bool) {
+	switch c.Query("view") {
+	case "", viewFinalized:
+		return false, true
+	case viewLatest:
+		if s.pending == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "latest view not enabled"})
+			return false, false
+		}
+		return true, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid view"})
+		return false, false
+	}
+}
+
+// pendingUnavailable 退回已确认视图时的提示
+const pendingUnavailable = "pending data unavailable, showing finalized view"
+
+// loadPendingChanges 读取 cursor 之后的 pending 区块，计算账户在已确认余额 base 之上的变化
+// pending 数据不可用时返回 ok=false（调用方退回已确认视图），不作为错误
+func (s *Server) loadPendingChanges(
+	ctx context.Context,
+	chainID int64,
+	contract, account string,
+	base *big.Int,
+) ([]indexer.PendingChange, bool, error) {
+
+	var cursor models.BlockCursor
+	if err := s.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=?", chainID, contract).
+		Limit(1).
+		Find(&cursor).Error; err != nil {
+		return nil, false, err
+	}
+
+	// 只取 cursor 之后的区块（已落库的残留不重复叠加）
+	blocks, err := s.pending.ListAfter(ctx, chainID, contract, cursor.BlockNumber)
+	if err != nil {
+		log.Printf("[api] latest view fallback chain=%d contract=%s: %v", chainID, contract, err)
+		return nil, false, nil
+	}
+
+	return indexer.PendingBalanceChanges(blocks, common.HexToAddress(account), base), true, nil
+}
+
+// pendingChangesJSON pending 变化的响应格式
+func pendingChangesJSON(changes []indexer.PendingChange, decimals int32) ([]gin.H, error) {
+	out := make([]gin.H, 0, len(changes))
+	for _, ch := range changes {
+		display, err := displayAmount(ch.BalanceAfter.String(), decimals)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, gin.H{
+			"block_number":          ch.BlockNumber,
+			"block_time":            ch.BlockTime,
+			"tx_hash":               ch.TxHash,
+			"log_index":             ch.LogIndex,
+			"delta":                 ch.Delta.String(),
+			"balance_after":         ch.BalanceAfter.String(),
+			"balance_after_display": display,
+		})
+	}
+	return out, nil
+}
+
+// latestPoints 估算截至 now 的积分：已结算部分 + 尚未结算（含 pending 区块）的部分
+// up 为 nil 表示账户还没有 user_point：从首次出现（balance_log 或 pending）开始估算，都没有时返回 nil
+// pending 数据不可用时返回 ok=false
+func (s *Server) latestPoints(
+	ctx context.Context,
+	chainID int64,
+	contract, account string,
+	up *models.UserPoint,
+	now time.Time,
+) (gin.H, bool, error) {
+
+	var sysC models.SysContract
+	if err := s.db.WithContext(ctx).
+		Where("chain_id = ? AND address = ?", chainID, contract).
+		First(&sysC).Error; err != nil {
+		return nil, false, err
+	}
+
+	// pending 变化叠加在最新的已确认余额之上
+	var ub models.UserBalance
+	if err := s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND account=?",
+			chainID, contract, account,
+		).
+		Limit(1).
+		Find(&ub).Error; err != nil {
+		return nil, false, err
+	}
+	base := big.NewInt(0)
+	if ub.Balance != "" {
+		if _, ok := base.SetString(ub.Balance, 10); !ok {
+			return nil, false, fmt.Errorf("invalid balance in db: %s", ub.Balance)
+		}
+	}
+
+	changes, ok, err := s.loadPendingChanges(ctx, chainID, contract, account, base)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+
+	settled := decimal.Zero
+	var from time.Time
+	if up != nil {
+		if settled, err = decimal.NewFromString(up.TotalPoints); err != nil {
+			return nil, false, fmt.Errorf("invalid total_points in db: %w", err)
+		}
+		from = up.LastCalcTime.UTC()
+	} else {
+		var first struct{ BlockTime *time.Time }
+		if err := s.db.WithContext(ctx).
+			Model(&models.BalanceLog{}).
+			Select("MIN(block_time) AS block_time").
+			Where(
+				"chain_id=? AND contract_address=? AND account=?",
+				chainID, contract, account,
+			).
+			Scan(&first).Error; err != nil {
+			return nil, false, err
+		}
+		switch {
+		case first.BlockTime != nil:
+			from = first.BlockTime.UTC()
+		case len(changes) > 0:
+			from = changes[0].BlockTime.UTC()
+		default:
+			return nil, true, nil
+		}
+	}
+
+	extra := make([]calculator.BalanceChange, 0, len(changes))
+	for _, ch := range changes {
+		extra = append(extra, calculator.BalanceChange{
+			At:           ch.BlockTime,
+			BlockNumber:  int64(ch.BlockNumber),
+			LogIndex:     int(ch.LogIndex),
+			BalanceAfter: ch.BalanceAfter,
+		})
+	}
+
+	unsettled := decimal.Zero
+	if now.After(from) {
+		delta, err := calculator.ComputePointsDeltaWith(
+			ctx,
+			s.db,
+			chainID,
+			contract,
+			account,
+			sysC.BalanceDecimals(),
+			from,
+			now,
+			extra,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		unsettled = delta.Total
+	}
+
+	return gin.H{
+		"confirmed":        false,
+		"as_of":            now,
+		"total_points":     settled.Add(unsettled).String(),
+		"unsettled_points": unsettled.String(),
+		"pending_changes":  len(changes),
+	}, true, nil
+}
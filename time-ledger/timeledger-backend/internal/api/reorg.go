@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /indexer/reorgs?chain_id=&contract=&min_depth=&limit=&offset=
+// 按检测时间倒序返回链重组记录；chain_id / contract 可选，不传则返回全部合约
+func (s *Server) GetReorgs(c *gin.Context) {
+	q := s.db.Model(&models.ReorgEvent{})
+
+	if v := c.Query("chain_id"); v != "" {
+		chainID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || chainID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+			return
+		}
+		q = q.Where("chain_id=?", chainID)
+	}
+	if contract := c.Query("contract"); contract != "" {
+		q = q.Where("contract_address=?", contract)
+	}
+	if v := c.Query("min_depth"); v != "" {
+		d, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid min_depth"})
+			return
+		}
+		q = q.Where("depth >= ?", d)
+	}
+
+	limit, offset := parsePage(c)
+
+	var rows []models.ReorgEvent
+	if err := q.
+		Order("detected_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
@@ -10,10 +10,14 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/indexer"
 )
 
 type Server struct {
 	db *gorm.DB
+
+	admin   *AdminDeps           // 运维接口，nil 表示未开启
+	pending indexer.PendingStore // latest 视图数据源，nil 表示未开启
 }
 
 func NewServer(db *gorm.DB) *Server {
@@ -24,10 +28,69 @@ func NewServer(db *gorm.DB) *Server {
 func (s *Server) Register(r *gin.Engine) {
 	r.GET("/head", s.GetHead)
 
+	r.GET("/user/balance", s.GetUserBalance)
 	r.GET("/user/points", s.GetUserPoints)
 	r.GET("/user/point_logs", s.GetUserPointLogs)
 
 	r.GET("/rate/current", s.GetCurrentRate)
+
+	r.GET("/transfers", s.GetTransfers)
+
+	r.GET("/token/info", s.GetTokenInfo)
+	r.GET("/token/supply_history", s.GetSupplyHistory)
+	r.GET("/token/allowances", s.GetAllowances)
+	r.GET("/token/ownership_history", s.GetOwnershipHistory)
+	r.GET("/token/holdings", s.GetHoldings)
+
+	r.GET("/contract/events", s.GetContractEvents)
+
+	r.GET("/indexer/anomalies", s.GetAnomalies)
+	r.GET("/indexer/sync_status", s.GetSyncStatus)
+	r.GET("/indexer/reorgs", s.GetReorgs)
+
+	r.GET("/calculator/runs", s.GetCalcRuns)
+
+	s.registerAdmin(r)
+}
+
+//
+// =======================
+// Helpers
+// =======================
+//
+
+// parseChainContract 解析必填的 chain_id / contract 参数，失败时已写好 400 响应
+func parseChainContract(c *gin.Context) (int64, string, bool) {
+	chainID, err := strconv.ParseInt(c.Query("chain_id"), 10, 64)
+	if err != nil || chainID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+		return 0, "", false
+	}
+
+	contract := c.Query("contract")
+	if contract == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing contract"})
+		return 0, "", false
+	}
+
+	return chainID, contract, true
+}
+
+// parsePage 解析 limit / offset，默认 100 / 0，limit 上限 1000
+func parsePage(c *gin.Context) (int, int) {
+	limit := 100
+	offset := 0
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			offset = n
+		}
+	}
+	return limit, offset
 }
 
 //
@@ -79,7 +142,8 @@ func (s *Server) GetHead(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
-// GET /user/points?chain_id=&contract=&account=
+// GET /user/points?chain_id=&contract=&account=&view=
+// view=latest 时额外返回截至当前时刻的积分估算（含 pending 区块，confirmed=false）
 func (s *Server) GetUserPoints(c *gin.Context) {
 	chainID, err := strconv.ParseInt(c.Query("chain_id"), 10, 64)
 	if err != nil || chainID == 0 {
@@ -94,6 +158,11 @@ func (s *Server) GetUserPoints(c *gin.Context) {
 		return
 	}
 
+	latest, ok := s.parseView(c)
+	if !ok {
+		return
+	}
+
 	// UserPoint 目前是单一大表，不需要分表逻辑
 	var up models.UserPoint
 	err = s.db.
@@ -103,22 +172,51 @@ func (s *Server) GetUserPoints(c *gin.Context) {
 		).
 		First(&up).Error
 
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user point not found"})
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"chain_id":         chainID,
+		"contract_address": contract,
+		"account":          account,
+	}
+	if found {
+		resp["account"] = up.Account
+		resp["total_points"] = up.TotalPoints
+		resp["last_calc_time"] = up.LastCalcTime
+	}
+
+	// latest 视图下账户可能只出现在 pending 中（或尚未被计算），与余额接口一致从首次出现开始估算
+	if latest {
+		var upPtr *models.UserPoint
+		if found {
+			upPtr = &up
+		}
+
+		pending, ok, err := s.latestPoints(c.Request.Context(), chainID, contract, account, upPtr, time.Now().UTC())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if !ok {
+			resp["view"] = viewFinalized
+			resp["notice"] = pendingUnavailable
+		} else if pending != nil {
+			resp["view"] = viewLatest
+			resp["pending"] = pending
+			found = true
+		}
+	}
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user point not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"chain_id":         up.ChainID,
-		"contract_address": up.ContractAddress,
-		"account":          up.Account,
-		"total_points":     up.TotalPoints,
-		"last_calc_time":   up.LastCalcTime,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
 // GET /user/point_logs?chain_id=&contract=&account=&from=&to=&limit=&offset=
@@ -236,6 +334,7 @@ func (s *Server) GetCurrentRate(c *gin.Context) {
 		"contract_address": pr.ContractAddress,
 		"rate_numerator":   pr.RateNumerator,
 		"rate_denominator": pr.RateDenominator,
+		"formula_id":       pr.FormulaID,
 		"effective_time":   pr.EffectiveTime,
 	})
 }
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /indexer/sync_status?chain_id=&contract=&state=
+// 所有参数可选，不传则返回全部合约的同步状态
+func (s *Server) GetSyncStatus(c *gin.Context) {
+	q := s.db.Model(&models.SyncStatus{})
+
+	if v := c.Query("chain_id"); v != "" {
+		chainID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || chainID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chain_id"})
+			return
+		}
+		q = q.Where("chain_id=?", chainID)
+	}
+	if contract := c.Query("contract"); contract != "" {
+		q = q.Where("contract_address=?", contract)
+	}
+	if state := c.Query("state"); state != "" {
+		q = q.Where("state=?", state)
+	}
+
+	var rows []models.SyncStatus
+	if err := q.Order("chain_id ASC, contract_address ASC").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /token/allowances?chain_id=&contract=&owner=&spender=&limit=&offset=
+// - owner：某地址授权给了谁
+// - spender：谁授权给了某 spender（授权敞口）
+// - 无限授权排在最前，其余按额度从大到小
+func (s *Server) GetAllowances(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	owner := c.Query("owner")
+	spender := c.Query("spender")
+	if owner == "" && spender == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "owner or spender required"})
+		return
+	}
+
+	limit, offset := parsePage(c)
+
+	q := s.db.
+		Model(&models.TokenAllowance{}).
+		Where("chain_id=? AND contract_address=?", chainID, contract).
+		Where("allowance <> '0'")
+
+	if owner != "" {
+		q = q.Where("owner=?", owner)
+	}
+	if spender != "" {
+		q = q.Where("spender=?", spender)
+	}
+
+	// allowance 为十进制字符串：先按长度再按字典序即为数值序
+	var rows []models.TokenAllowance
+	if err := q.
+		Order("unlimited DESC, CHAR_LENGTH(allowance) DESC, allowance DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// GET /token/ownership_history?chain_id=&contract=&limit=&offset=
+func (s *Server) GetOwnershipHistory(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePage(c)
+
+	var rows []models.OwnershipHistory
+	if err := s.db.
+		Where("chain_id=? AND contract_address=?", chainID, contract).
+		Order("block_number DESC, log_index DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// GET /token/holdings?chain_id=&contract=&account=&token_id=&limit=&offset=
+// NFT 持仓：account 查某地址持有哪些 tokenId，token_id 查某 tokenId 的持有人
+func (s *Server) GetHoldings(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	account := c.Query("account")
+	tokenID := c.Query("token_id")
+	if account == "" && tokenID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account or token_id required"})
+		return
+	}
+
+	limit, offset := parsePage(c)
+
+	q := s.db.
+		Model(&models.TokenHolding{}).
+		Where("chain_id=? AND contract_address=?", chainID, contract)
+
+	if account != "" {
+		q = q.Where("account=?", account)
+	}
+	if tokenID != "" {
+		q = q.Where("token_id=?", tokenID)
+	}
+
+	var rows []models.TokenHolding
+	if err := q.
+		Order("block_number DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
+
+// GET /token/info?chain_id=&contract=
+// 合约元数据 + 最新供应量 / 持有人数
+func (s *Server) GetTokenInfo(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	var sysC models.SysContract
+	if err := s.db.Where("chain_id = ? AND address = ?", chainID, contract).First(&sysC).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "contract not configured"})
+		return
+	}
+
+	resp := gin.H{
+		"chain_id":           sysC.ChainID,
+		"contract_address":   sysC.Address,
+		"alias":              sysC.Name,
+		"name":               sysC.TokenName,
+		"symbol":             sysC.TokenSymbol,
+		"decimals":           sysC.BalanceDecimals(),
+		"token_standard":     sysC.TokenStandard,
+		"owner":              sysC.Owner,
+		"metadata_synced_at": sysC.MetadataSyncedAt,
+	}
+
+	var latest models.TokenSupply
+	if err := s.db.
+		Where("chain_id=? AND contract_address=?", chainID, contract).
+		Order("block_number DESC").
+		Limit(1).
+		Find(&latest).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if latest.ID != 0 {
+		display, err := displayAmount(latest.TotalSupply, sysC.BalanceDecimals())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp["total_supply"] = latest.TotalSupply
+		resp["total_supply_display"] = display
+		resp["holder_count"] = latest.HolderCount
+		resp["supply_block_number"] = latest.BlockNumber
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /token/supply_history?chain_id=&contract=&from_block=&to_block=&limit=&offset=
+// 每个发生 mint / burn 的区块一条，按区块升序
+func (s *Server) GetSupplyHistory(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePage(c)
+
+	q := s.db.
+		Model(&models.TokenSupply{}).
+		Where("chain_id=? AND contract_address=?", chainID, contract)
+
+	if v := c.Query("from_block"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			q = q.Where("block_number >= ?", n)
+		}
+	}
+	if v := c.Query("to_block"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			q = q.Where("block_number <= ?", n)
+		}
+	}
+
+	var rows []models.TokenSupply
+	if err := q.
+		Order("block_number ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// GET /transfers?chain_id=&contract=&tx_hash=&account=&from_address=&to_address=&kind=&from_block=&to_block=&limit=&offset=
+// - account：from 或 to 任一匹配
+// - from_address + to_address 同时传入即 "A -> B 的所有转账"
+// - kind：mint | burn | transfer
+func (s *Server) GetTransfers(c *gin.Context) {
+	chainID, contract, ok := parseChainContract(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePage(c)
+
+	q := s.db.
+		Model(&models.TransferEvent{}).
+		Where("chain_id=? AND contract_address=?", chainID, contract)
+
+	if v := c.Query("tx_hash"); v != "" {
+		q = q.Where("tx_hash=?", v)
+	}
+	if v := c.Query("account"); v != "" {
+		q = q.Where("(from_address=? OR to_address=?)", v, v)
+	}
+	if v := c.Query("from_address"); v != "" {
+		q = q.Where("from_address=?", v)
+	}
+	if v := c.Query("to_address"); v != "" {
+		q = q.Where("to_address=?", v)
+	}
+	if v := c.Query("kind"); v != "" {
+		q = q.Where("kind=?", v)
+	}
+	if v := c.Query("from_block"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			q = q.Where("block_number >= ?", n)
+		}
+	}
+	if v := c.Query("to_block"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			q = q.Where("block_number <= ?", n)
+		}
+	}
+
+	var rows []models.TransferEvent
+	if err := q.
+		Order("block_number DESC, log_index DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rows)
+}
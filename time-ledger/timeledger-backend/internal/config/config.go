@@ -5,6 +5,10 @@ type Config struct {
 	Database DatabaseConfig `toml:"database"`
 	Redis    RedisConfig    `toml:"redis"`
 	Chains   []ChainConfig  `toml:"chains"`
+
+	Coordination CoordinationConfig `toml:"coordination"`
+	Calculator   CalculatorConfig   `toml:"calculator"`
+	Points       PointsConfig       `toml:"points"`
 }
 
 type AppConfig struct {
@@ -18,7 +22,37 @@ type DatabaseConfig struct {
 }
 
 type RedisConfig struct {
-	KeyPrefix string `toml:"key_prefix"`
+	KeyPrefix     string `toml:"key_prefix"`
+	PendingTTLSec int64  `toml:"pending_ttl_sec"` // OP Stack pending 区块保留时长（秒），默认 86400
+}
+
+// CoordinationConfig 多副本协调（租约）配置
+type CoordinationConfig struct {
+	InstanceID        string `toml:"instance_id"`         // 实例标识，为空时使用 hostname-pid
+	LeaseTTLSec       int64  `toml:"lease_ttl_sec"`       // 租约有效期（秒），默认 60
+	MaxOwnedContracts int    `toml:"max_owned_contracts"` // 每个实例最多持有的合约数，0 = 不限制（热备模式）
+}
+
+// CalculatorConfig 积分计算并发配置
+type CalculatorConfig struct {
+	ContractWorkers int `toml:"contract_workers"` // 并发计算的合约数，默认 1
+	ShardWorkers    int `toml:"shard_workers"`    // 单合约内并发计算的账户分片数，默认 1
+	ShardSize       int `toml:"shard_size"`       // 每个分片的账户数，默认 1000
+	DBConcurrency   int `toml:"db_concurrency"`   // 同时进行的积分写事务上限，0 = contract_workers * shard_workers
+}
+
+// 未配置 [points] 时的初始积分规则：每小时每单位余额 5/100 积分
+const (
+	DefaultRateNumerator   = 5
+	DefaultRateDenominator = 100
+)
+
+// PointsConfig 积分规则配置
+type PointsConfig struct {
+	// 新合约的初始积分规则（每小时每单位余额 numerator / denominator 积分），可被合约级配置覆盖
+	// denominator 为 0（未配置）时使用 DefaultRateNumerator / DefaultRateDenominator
+	DefaultRateNumerator   int64 `toml:"default_rate_numerator"`
+	DefaultRateDenominator int64 `toml:"default_rate_denominator"`
 }
 
 type ChainConfig struct {
@@ -31,6 +65,9 @@ type ChainConfig struct {
 	ChunkSize      uint64 `toml:"chunk_size"`       // 每次同步的区块数量，默认 10
 	RequestDelayMs int64  `toml:"request_delay_ms"` // 每次请求之间的延迟（毫秒），默认 100
 
+	ContractWorkers int `toml:"contract_workers"`   // 链内并发处理的合约数，默认 1
+	MaxChunksPerRun int `toml:"max_chunks_per_run"` // 单合约每轮最多处理的 chunk 数，0 = 不限制
+
 	Contracts []ContractConfig `toml:"contracts"`
 
 	// 派生字段（不来自 toml）
@@ -38,7 +75,42 @@ type ChainConfig struct {
 }
 
 type ContractConfig struct {
+	Name          string `toml:"name"` // 合约别名，为空时使用链上 symbol
 	Address       string `toml:"address"`
-	StartBlock    int64  `toml:"start_block"`
-	TokenDecimals int64  `toml:"token_decimals"`
+	StartBlock    int64  `toml:"start_block"`    // 0 或不写 = 自动探测部署区块（需要 archive 节点）
+	TokenDecimals int64  `toml:"token_decimals"` // 可选覆盖值，0 = 以链上 decimals() 为准
+	TokenStandard string `toml:"token_standard"` // erc20 | erc721 | erc1155，默认 erc20
+	AnomalyPolicy string `toml:"anomaly_policy"` // 负余额处理策略：halt | clamp | skip，默认 halt
+
+	// 记账模式：transfer（按 Transfer 累加，默认）| snapshot（Transfer 仅标记账户，余额取链上 balanceOf）
+	// snapshot 用于 rebase / 转账收税等 Transfer 与 balanceOf 对不上的代币，需要 archive 节点
+	AccountingMode    string `toml:"accounting_mode"`
+	RebaseSweepBlocks int64  `toml:"rebase_sweep_blocks"` // snapshot 模式下每跨过 N 的整数倍区块全量核对一次余额，0 = 关闭
+
+	// 通用事件索引：按 ABI 解码 events 中列出的事件，写入 contract_event
+	ABIPath string   `toml:"abi_path"` // ABI 文件路径（相对 config 文件所在目录），支持 hardhat / foundry 产物
+	Events  []string `toml:"events"`   // 需要索引的事件名
+
+	// 积分计算调度：cron（5 段，UTC）或 @every <duration>，默认 @hourly
+	// calc_trigger = event 时 indexer 推进 cursor 后也会尽快触发计算
+	CalcSchedule string `toml:"calc_schedule"`
+	CalcTrigger  string `toml:"calc_trigger"` // schedule | event，默认 schedule
+
+	// 初始积分规则，0 = 使用 [points] 的默认值；仅在合约首次初始化时写入 point_rate
+	RateNumerator   int64 `toml:"rate_numerator"`
+	RateDenominator int64 `toml:"rate_denominator"`
+
+	// 派生字段（不来自 toml）
+	ABIJSON string `toml:"-"`
+}
+
+// InitialRate 合约的初始积分规则：合约级配置优先，其次 [points]，都未配置时取内置默认值
+func (c ContractConfig) InitialRate(p PointsConfig) (numerator, denominator int64) {
+	if c.RateDenominator != 0 {
+		return c.RateNumerator, c.RateDenominator
+	}
+	if p.DefaultRateDenominator != 0 {
+		return p.DefaultRateNumerator, p.DefaultRateDenominator
+	}
+	return DefaultRateNumerator, DefaultRateDenominator
 }
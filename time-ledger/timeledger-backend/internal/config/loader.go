@@ -1,8 +1,11 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/joho/godotenv"
 	"github.com/pelletier/go-toml/v2"
@@ -40,6 +43,23 @@ func Load(configPath string) (*Config, error) {
 		}
 
 		chain.RPCURL = rpcURL
+
+		//注入合约 ABI
+		for j := range chain.Contracts {
+			contract := &chain.Contracts[j]
+			if contract.ABIPath == "" {
+				continue
+			}
+
+			abiJSON, err := loadABI(configPath, contract.ABIPath)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"load abi for contract %s on chain %s failed: %w",
+					contract.Address, chain.Name, err,
+				)
+			}
+			contract.ABIJSON = abiJSON
+		}
 	}
 
 	//启动期校验
@@ -49,3 +69,32 @@ func Load(configPath string) (*Config, error) {
 
 	return &cfg, nil
 }
+
+// loadABI 读取 ABI 文件，相对路径以 config 文件所在目录为基准
+// 既支持纯 ABI 数组，也支持 hardhat / foundry 编译产物（取其中的 "abi" 字段）
+func loadABI(configPath, abiPath string) (string, error) {
+	if !filepath.IsAbs(abiPath) {
+		abiPath = filepath.Join(filepath.Dir(configPath), abiPath)
+	}
+
+	data, err := os.ReadFile(abiPath)
+	if err != nil {
+		return "", err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var artifact struct {
+			ABI json.RawMessage `json:"abi"`
+		}
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return "", err
+		}
+		if len(artifact.ABI) == 0 {
+			return "", fmt.Errorf("%s has no abi field", abiPath)
+		}
+		data = artifact.ABI
+	}
+
+	return string(data), nil
+}
@@ -1,12 +1,43 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/schedule"
+)
 
 func Validate(cfg *Config) error {
 	if len(cfg.Chains) == 0 {
 		return fmt.Errorf("no chains configured")
 	}
 
+	if cfg.Redis.PendingTTLSec < 0 {
+		return fmt.Errorf("redis.pending_ttl_sec must be >= 0")
+	}
+
+	if cfg.Coordination.LeaseTTLSec < 0 {
+		return fmt.Errorf("coordination.lease_ttl_sec must be >= 0")
+	}
+	if cfg.Coordination.MaxOwnedContracts < 0 {
+		return fmt.Errorf("coordination.max_owned_contracts must be >= 0")
+	}
+
+	cc := cfg.Calculator
+	if cc.ContractWorkers < 0 || cc.ShardWorkers < 0 || cc.ShardSize < 0 || cc.DBConcurrency < 0 {
+		return fmt.Errorf("calculator.contract_workers / shard_workers / shard_size / db_concurrency must be >= 0")
+	}
+
+	// [points] 可省略；只校验显式配置的值
+	if cfg.Points.DefaultRateNumerator < 0 || cfg.Points.DefaultRateDenominator < 0 {
+		return fmt.Errorf("points.default_rate_numerator / default_rate_denominator must be >= 0")
+	}
+	if cfg.Points.DefaultRateNumerator != 0 && cfg.Points.DefaultRateDenominator == 0 {
+		return fmt.Errorf("points.default_rate_numerator requires default_rate_denominator")
+	}
+
 	for _, chain := range cfg.Chains {
 		if chain.ChainID == 0 {
 			return fmt.Errorf("chain %s has invalid chain_id", chain.Name)
@@ -34,6 +65,13 @@ func Validate(cfg *Config) error {
 			)
 		}
 
+		if chain.ContractWorkers < 0 || chain.MaxChunksPerRun < 0 {
+			return fmt.Errorf(
+				"chain %s requires contract_workers >= 0 and max_chunks_per_run >= 0",
+				chain.Name,
+			)
+		}
+
 		if len(chain.Contracts) == 0 {
 			return fmt.Errorf(
 				"chain %s has no contracts configured",
@@ -45,14 +83,137 @@ func Validate(cfg *Config) error {
 			if c.Address == "" {
 				return fmt.Errorf("chain %s has empty contract address", chain.Name)
 			}
-			if c.TokenDecimals != 18 {
+			if c.StartBlock < 0 {
+				return fmt.Errorf(
+					"contract %s on chain %s has negative start_block",
+					c.Address, chain.Name,
+				)
+			}
+
+			switch c.TokenStandard {
+			case "", "erc20":
+				// 0 = 以链上 decimals() 为准；uint8 上限 255，但超过 77 位的精度在 decimal(65,0) 下没有意义
+				if c.TokenDecimals < 0 || c.TokenDecimals > 77 {
+					return fmt.Errorf(
+						"contract %s on chain %s has invalid token_decimals %d",
+						c.Address, chain.Name, c.TokenDecimals,
+					)
+				}
+			case "erc721", "erc1155":
+				// NFT 按个数计，没有精度
+				if c.TokenDecimals != 0 {
+					return fmt.Errorf(
+						"%s contract %s on chain %s must not set token_decimals",
+						c.TokenStandard, c.Address, chain.Name,
+					)
+				}
+			default:
+				return fmt.Errorf(
+					"contract %s on chain %s has unknown token_standard %s",
+					c.Address, chain.Name, c.TokenStandard,
+				)
+			}
+
+			switch c.AnomalyPolicy {
+			case "", "halt", "clamp", "skip":
+			default:
+				return fmt.Errorf(
+					"contract %s on chain %s has unknown anomaly_policy %s",
+					c.Address, chain.Name, c.AnomalyPolicy,
+				)
+			}
+
+			switch c.AccountingMode {
+			case "", "transfer":
+				if c.RebaseSweepBlocks != 0 {
+					return fmt.Errorf(
+						"contract %s on chain %s: rebase_sweep_blocks requires accounting_mode = snapshot",
+						c.Address, chain.Name,
+					)
+				}
+			case "snapshot":
+				if c.TokenStandard != "" && c.TokenStandard != "erc20" {
+					return fmt.Errorf(
+						"contract %s on chain %s: snapshot accounting only supports erc20",
+						c.Address, chain.Name,
+					)
+				}
+				if c.RebaseSweepBlocks < 0 {
+					return fmt.Errorf(
+						"contract %s on chain %s: rebase_sweep_blocks must be >= 0",
+						c.Address, chain.Name,
+					)
+				}
+			default:
+				return fmt.Errorf(
+					"contract %s on chain %s has unknown accounting_mode %s",
+					c.Address, chain.Name, c.AccountingMode,
+				)
+			}
+
+			if _, err := schedule.Parse(c.CalcSchedule); err != nil {
+				return fmt.Errorf(
+					"contract %s on chain %s: calc_schedule: %w",
+					c.Address, chain.Name, err,
+				)
+			}
+			switch c.CalcTrigger {
+			case "", "schedule", "event":
+			default:
+				return fmt.Errorf(
+					"contract %s on chain %s has unknown calc_trigger %s",
+					c.Address, chain.Name, c.CalcTrigger,
+				)
+			}
+
+			if c.RateNumerator < 0 || c.RateDenominator < 0 {
+				return fmt.Errorf(
+					"contract %s on chain %s: rate_numerator / rate_denominator must be >= 0",
+					c.Address, chain.Name,
+				)
+			}
+			if c.RateNumerator != 0 && c.RateDenominator == 0 {
 				return fmt.Errorf(
-					"contract %s on chain %s must have 18 decimals",
+					"contract %s on chain %s: rate_numerator requires rate_denominator",
 					c.Address, chain.Name,
 				)
 			}
+
+			if err := validateEvents(c); err != nil {
+				return fmt.Errorf(
+					"contract %s on chain %s: %w",
+					c.Address, chain.Name, err,
+				)
+			}
 		}
 	}
 
 	return nil
 }
+
+// validateEvents 校验通用事件配置：events 必须能在 ABI 中找到
+func validateEvents(c ContractConfig) error {
+	if len(c.Events) == 0 {
+		return nil
+	}
+	if c.ABIJSON == "" {
+		return fmt.Errorf("events configured but abi_path is empty")
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(c.ABIJSON))
+	if err != nil {
+		return fmt.Errorf("parse abi failed: %w", err)
+	}
+
+	seen := make(map[string]bool, len(c.Events))
+	for _, name := range c.Events {
+		if _, ok := parsed.Events[name]; !ok {
+			return fmt.Errorf("event %s not found in abi", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("event %s listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package formula
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+/*
+Formula
+-------
+积分规则的计算公式（JSON 定义，存于 rate_formula，按版本不可变）：
+
+	{
+	  "cap":   "100000",                      // 可选：参与计算的余额上限（代币单位）
+	  "tiers": [                              // 可选：分段累进倍率，above 升序且第一档为 0
+	    {"above": "0",     "multiplier": "1"},
+	    {"above": "10000", "multiplier": "1.5"}
+	  ],
+	  "curve": "linear"                       // linear（默认）| sqrt | log（ln(1 + x)）
+	}
+
+计算顺序：余额 → cap 截断 → 按档累进加权（每档只对落在该档的部分乘倍率）→ curve，
+得到“有效余额”，积分 = 有效余额 * rate（num/den）* 秒数 / 3600
+*/
+
+const (
+	CurveLinear = "linear"
+	CurveSqrt   = "sqrt"
+	CurveLog    = "log"
+)
+
+// curvePrecision sqrt / log 的计算精度（小数位）
+const curvePrecision = 18
+
+type Tier struct {
+	Above      decimal.Decimal `json:"above"`
+	Multiplier decimal.Decimal `json:"multiplier"`
+}
+
+type Formula struct {
+	Cap   *decimal.Decimal `json:"cap,omitempty"`
+	Tiers []Tier           `json:"tiers,omitempty"`
+	Curve string           `json:"curve,omitempty"`
+}
+
+// Parse 解析并校验公式定义
+func Parse(raw []byte) (*Formula, error) {
+	var f Formula
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("invalid formula json: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (f *Formula) Validate() error {
+	if f.Cap != nil && !f.Cap.IsPositive() {
+		return fmt.Errorf("cap must be > 0")
+	}
+
+	for i, t := range f.Tiers {
+		if i == 0 && !t.Above.IsZero() {
+			return fmt.Errorf("first tier must start at 0")
+		}
+		if i > 0 && !t.Above.GreaterThan(f.Tiers[i-1].Above) {
+			return fmt.Errorf("tier %d: above must be strictly increasing", i)
+		}
+		if t.Multiplier.IsNegative() {
+			return fmt.Errorf("tier %d: multiplier must be >= 0", i)
+		}
+	}
+
+	switch f.Curve {
+	case "", CurveLinear, CurveSqrt, CurveLog:
+	default:
+		return fmt.Errorf("unknown curve %q", f.Curve)
+	}
+	return nil
+}
+
+// EffectiveBalance 按公式把余额（代币单位）换算为参与计算的有效余额
+func (f *Formula) EffectiveBalance(balance decimal.Decimal) (decimal.Decimal, error) {
+	b := balance
+	if !b.IsPositive() {
+		return decimal.Zero, nil
+	}
+
+	if f.Cap != nil && b.GreaterThan(*f.Cap) {
+		b = *f.Cap
+	}
+
+	if len(f.Tiers) > 0 {
+		weighted := decimal.Zero
+		for i, t := range f.Tiers {
+			if !b.GreaterThan(t.Above) {
+				break
+			}
+			upper := b
+			if i+1 < len(f.Tiers) && f.Tiers[i+1].Above.LessThan(upper) {
+				upper = f.Tiers[i+1].Above
+			}
+			weighted = weighted.Add(upper.Sub(t.Above).Mul(t.Multiplier))
+		}
+		b = weighted
+	}
+
+	if !b.IsPositive() {
+		return decimal.Zero, nil
+	}
+
+	switch f.Curve {
+	case CurveSqrt:
+		return b.PowWithPrecision(decimal.RequireFromString("0.5"), curvePrecision)
+	case CurveLog:
+		return b.Add(decimal.NewFromInt(1)).Ln(curvePrecision)
+	default:
+		return b, nil
+	}
+}
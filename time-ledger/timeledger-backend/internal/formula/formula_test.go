@@ -0,0 +1,93 @@
+package formula
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestEffectiveBalance(t *testing.T) {
+	cases := []struct {
+		name    string
+		def     string
+		balance string
+		want    string
+	}{
+		{"linear", `{}`, "123.5", "123.5"},
+		{"cap", `{"cap": "1000"}`, "5000", "1000"},
+		{"cap not reached", `{"cap": "1000"}`, "200", "200"},
+		{
+			"tiers marginal",
+			`{"tiers": [{"above": "0", "multiplier": "1"}, {"above": "10000", "multiplier": "1.5"}]}`,
+			"12000",
+			"13000", // 10000 * 1 + 2000 * 1.5
+		},
+		{
+			"tiers below second",
+			`{"tiers": [{"above": "0", "multiplier": "1"}, {"above": "10000", "multiplier": "1.5"}]}`,
+			"500",
+			"500",
+		},
+		{
+			"cap then tiers",
+			`{"cap": "20000", "tiers": [{"above": 0, "multiplier": 1}, {"above": 10000, "multiplier": 0}]}`,
+			"50000",
+			"10000",
+		},
+		{"sqrt", `{"curve": "sqrt"}`, "10000", "100"},
+		{"zero balance", `{"curve": "log"}`, "0", "0"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := Parse([]byte(tc.def))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			got, err := f.EffectiveBalance(decimal.RequireFromString(tc.balance))
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+
+			want := decimal.RequireFromString(tc.want)
+			if !got.Round(12).Equal(want) {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestEffectiveBalanceLog(t *testing.T) {
+	f, err := Parse([]byte(`{"curve": "log"}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	// ln(1 + (e - 1)) = 1
+	e := decimal.RequireFromString("2.718281828459045235")
+	got, err := f.EffectiveBalance(e.Sub(decimal.NewFromInt(1)))
+	if err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if !got.Round(12).Equal(decimal.NewFromInt(1)) {
+		t.Fatalf("got %s, want 1", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	defs := []string{
+		`{"cap": "0"}`,
+		`{"curve": "cubic"}`,
+		`{"tiers": [{"above": "100", "multiplier": "1"}]}`,
+		`{"tiers": [{"above": "0", "multiplier": "1"}, {"above": "0", "multiplier": "2"}]}`,
+		`{"tiers": [{"above": "0", "multiplier": "-1"}]}`,
+		`not json`,
+	}
+
+	for _, def := range defs {
+		if _, err := Parse([]byte(def)); err == nil {
+			t.Errorf("expected error for %s", def)
+		}
+	}
+}
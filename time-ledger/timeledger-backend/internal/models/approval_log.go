@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ApprovalLog 原始 Approval 事件流水（事实表）
+// token_allowance 由它派生，回滚后可据此重建
+type ApprovalLog struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_approval,unique,priority:1;index:idx_owner_spender,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_approval,unique,priority:2;index:idx_owner_spender,priority:2"`
+
+	Owner   string `gorm:"type:char(42);not null;index:idx_owner_spender,priority:3"`
+	Spender string `gorm:"type:char(42);not null;index:idx_owner_spender,priority:4"`
+
+	// uint256，无限授权（2^256-1）超出 decimal(65,0)，按十进制字符串存储
+	Value string `gorm:"type:varchar(78);not null"`
+
+	BlockNumber int64     `gorm:"not null;index:uniq_approval,unique,priority:3;index:idx_owner_spender,priority:5"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+
+	TxHash   string `gorm:"type:char(66);not null"`
+	LogIndex int64  `gorm:"not null;index:uniq_approval,unique,priority:4"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (ApprovalLog) TableName() string { return "approval_log" }
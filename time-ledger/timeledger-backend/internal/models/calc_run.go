@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 积分计算运行状态
+const (
+	CalcRunRunning = "running"
+	CalcRunSuccess = "success"
+	CalcRunPartial = "partial" // 完成但有账户重试后仍失败（下一轮继续重试）
+	CalcRunFailed  = "failed"
+)
+
+// CalcRun 单个合约的一次积分计算记录
+type CalcRun struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:idx_contract_started,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:idx_contract_started,priority:2"`
+
+	// 本轮使用的 safe block 时间（积分结算上界）
+	SafeTime time.Time `gorm:"type:datetime(6);not null"`
+
+	StartedAt  time.Time  `gorm:"type:datetime(6);not null;index:idx_contract_started,priority:3"`
+	FinishedAt *time.Time `gorm:"type:datetime(6)"`
+	Status     string     `gorm:"type:varchar(16);not null;index"`
+
+	IdleAccounts      int64  `gorm:"not null;default:0"` // 批量结算的 idle 账户
+	AccountsProcessed int64  `gorm:"not null;default:0"` // 分段计算成功的账户
+	AccountsFailed    int64  `gorm:"not null;default:0"` // 重试后仍失败的账户
+	PointsIssued      string `gorm:"type:decimal(38,18);not null;default:0"`
+
+	// 失败账户及错误样本（条数有上限）
+	ErrorSamples json.RawMessage `gorm:"type:json"`
+	Error        string          `gorm:"type:text"`
+}
+
+func (CalcRun) TableName() string { return "calc_run" }
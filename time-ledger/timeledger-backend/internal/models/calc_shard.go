@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// 积分计算分片状态
+const (
+	CalcShardPending = "pending" // 未完成（含进行中、进程崩溃后待续跑）
+	CalcShardDone    = "done"
+)
+
+// CalcShard 一次积分计算（run_time 为结算上界）按账户区间切分的分片及其进度
+// 进程崩溃后下一轮按同一 run_time 续跑未完成的分片，从 last_account 之后继续
+type CalcShard struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64     `gorm:"not null;index:uniq_calc_shard,unique,priority:1"`
+	ContractAddress string    `gorm:"type:char(42);not null;index:uniq_calc_shard,unique,priority:2"`
+	RunTime         time.Time `gorm:"type:datetime(6);not null;index:uniq_calc_shard,unique,priority:3"`
+	ShardNo         int       `gorm:"not null;index:uniq_calc_shard,unique,priority:4"`
+
+	// 账户区间 [from_account, to_account]
+	FromAccount string `gorm:"type:char(42);not null"`
+	ToAccount   string `gorm:"type:char(42);not null"`
+
+	Status      string `gorm:"type:varchar(16);not null;index"`
+	LastAccount string `gorm:"type:char(42);not null;default:''"` // 已处理到的账户（含）
+	Processed   int64  `gorm:"not null;default:0"`
+	Failed      int64  `gorm:"not null;default:0"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"type:datetime(6);not null;autoUpdateTime"`
+}
+
+func (CalcShard) TableName() string { return "calc_shard" }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ContractEvent 按配置 ABI 解码的通用事件流水
+// - topic0 为事件签名，topic1~3 为 indexed 参数的原始 32 字节（不足则为 NULL），便于按地址等条件走索引
+// - args 为全部参数（含 indexed）按参数名解码后的 JSON，大整数以十进制字符串表示
+// - 与 transfer_event 一样在 chunk 事务中写入，唯一索引保证幂等，reorg 时按 block 回滚
+type ContractEvent struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_contract_event,unique,priority:1;index:idx_event,priority:1;index:idx_topic1,priority:1;index:idx_topic2,priority:1;index:idx_topic3,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_contract_event,unique,priority:2;index:idx_event,priority:2;index:idx_topic1,priority:2;index:idx_topic2,priority:2;index:idx_topic3,priority:2"`
+
+	EventName string `gorm:"type:varchar(128);not null;index:idx_event,priority:3"`
+
+	Topic0 string  `gorm:"type:char(66);not null;index:idx_topic1,priority:3;index:idx_topic2,priority:3;index:idx_topic3,priority:3"`
+	Topic1 *string `gorm:"type:char(66);index:idx_topic1,priority:4"`
+	Topic2 *string `gorm:"type:char(66);index:idx_topic2,priority:4"`
+	Topic3 *string `gorm:"type:char(66);index:idx_topic3,priority:4"`
+
+	Args json.RawMessage `gorm:"type:json;not null"`
+
+	BlockNumber int64     `gorm:"not null;index:uniq_contract_event,unique,priority:3;index:idx_event,priority:4"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+
+	TxHash   string `gorm:"type:char(66);not null"`
+	LogIndex int64  `gorm:"not null;index:uniq_contract_event,unique,priority:4"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (ContractEvent) TableName() string { return "contract_event" }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// 异常处理策略（按合约配置）
+const (
+	AnomalyPolicyHalt  = "halt"  // 中断当前 chunk，合约停在原地等待人工处理（默认）
+	AnomalyPolicyClamp = "clamp" // 余额截断为 0 后继续
+	AnomalyPolicySkip  = "skip"  // 跳过该账户的本次变动后继续
+)
+
+// 异常类型
+const (
+	AnomalyKindNegativeBalance = "negative_balance"
+	AnomalyKindNegativeHolding = "negative_holding" // NFT tokenId 维度持仓为负
+)
+
+// IndexerAnomaly 索引异常隔离表
+// 记录触发异常的事件及上下文，以及当时采用的处理策略，
+// 唯一索引保证同一事件对同一账户（同一类型、同一 tokenId）只记录一次（重试幂等）
+type IndexerAnomaly struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_anomaly_event,unique,priority:1;index:idx_contract_time,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_anomaly_event,unique,priority:2;index:idx_contract_time,priority:2"`
+	Account         string `gorm:"type:char(42);not null;index:uniq_anomaly_event,unique,priority:3"`
+
+	Kind   string `gorm:"type:varchar(32);not null;index:uniq_anomaly_event,unique,priority:6"`
+	Policy string `gorm:"type:varchar(16);not null"`
+
+	// negative_holding 的 tokenId（其他类型为空）
+	TokenID string `gorm:"type:varchar(78);not null;default:'';index:uniq_anomaly_event,unique,priority:7"`
+
+	// 异常发生时的余额（negative_holding 为该 tokenId 的持仓）上下文
+	BalanceBefore string `gorm:"type:decimal(65,0);not null"`
+	Delta         string `gorm:"type:decimal(65,0);not null"`
+	BalanceAfter  string `gorm:"type:decimal(65,0);not null"` // 按原始 delta 计算出的（负）余额
+
+	// 触发异常的事件
+	BlockNumber int64     `gorm:"not null;index:uniq_anomaly_event,unique,priority:4"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+	TxHash      string    `gorm:"type:char(66);not null"`
+	LogIndex    int64     `gorm:"not null;index:uniq_anomaly_event,unique,priority:5"`
+	FromAddress string    `gorm:"type:char(42);not null"`
+	ToAddress   string    `gorm:"type:char(42);not null"`
+	Value       string    `gorm:"type:decimal(65,0);not null"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime;index:idx_contract_time,priority:3"`
+}
+
+func (IndexerAnomaly) TableName() string { return "indexer_anomaly" }
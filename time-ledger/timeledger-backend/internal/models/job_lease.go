@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// JobLease 分布式任务租约表
+// 每个 (角色, chain, contract) 一条，例如 "indexer:84532:0xB8a3..."
+// 持有者在 ExpiresAt 之前独占该任务；崩溃后租约过期，由其他副本接管。
+// FencingToken 在每次易主时递增，写事务用它拒绝过期持有者的写入。
+type JobLease struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	Name  string `gorm:"type:varchar(128);not null;uniqueIndex"`
+	Owner string `gorm:"type:varchar(128);not null"`
+
+	FencingToken int64     `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"type:datetime(6);not null"`
+
+	UpdatedAt time.Time `gorm:"type:datetime(6);not null;autoUpdateTime"`
+}
+
+func (JobLease) TableName() string { return "job_lease" }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// OwnershipHistory 合约 owner 变更历史（OwnershipTransferred）
+type OwnershipHistory struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_ownership,unique,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_ownership,unique,priority:2"`
+
+	PreviousOwner string `gorm:"type:char(42);not null"`
+	NewOwner      string `gorm:"type:char(42);not null"`
+
+	BlockNumber int64     `gorm:"not null;index:uniq_ownership,unique,priority:3"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+
+	TxHash   string `gorm:"type:char(66);not null"`
+	LogIndex int64  `gorm:"not null;index:uniq_ownership,unique,priority:4"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (OwnershipHistory) TableName() string { return "ownership_history" }
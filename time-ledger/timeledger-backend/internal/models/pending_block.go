@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PendingBlock OP Stack 未确认区块暂存（未配置 Redis 时使用）
+// payload 为完整的区块事件 JSON，<= safe block 后由 indexer flush 到业务表并删除
+type PendingBlock struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_pending_block,unique,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_pending_block,unique,priority:2"`
+	BlockNumber     int64  `gorm:"not null;index:uniq_pending_block,unique,priority:3"`
+	BlockHash       string `gorm:"type:char(66);not null"`
+
+	Payload json.RawMessage `gorm:"type:json;not null"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null"`
+	UpdatedAt time.Time `gorm:"type:datetime(6);not null"`
+}
+
+func (PendingBlock) TableName() string { return "pending_block" }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// PointBoost 账户级积分倍率（如早期用户 1.5x、活动期间 2x）
+// 生效区间 [start_time, end_time)，end_time 为空表示长期有效；同一时刻多条重叠时倍率相乘
+type PointBoost struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:idx_boost_account,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:idx_boost_account,priority:2"`
+	Account         string `gorm:"type:char(42);not null;index:idx_boost_account,priority:3"`
+
+	Multiplier string `gorm:"type:decimal(20,8);not null"`
+
+	StartTime time.Time  `gorm:"type:datetime(6);not null;index:idx_boost_account,priority:4"`
+	EndTime   *time.Time `gorm:"type:datetime(6)"`
+
+	Reason string `gorm:"type:varchar(64);not null;default:''"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (PointBoost) TableName() string { return "point_boost" }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// PointCorrection 积分修正审计记录
+// 回溯重算时每个积分发生变化的账户写一条，与积分调整在同一事务中提交
+type PointCorrection struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	JobID uint64 `gorm:"not null;index"`
+
+	ChainID         int64  `gorm:"not null;index:idx_correction_account,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:idx_correction_account,priority:2"`
+	Account         string `gorm:"type:char(42);not null;index:idx_correction_account,priority:3"`
+
+	// 重算区间 [from_time, to_time)
+	FromTime time.Time `gorm:"type:datetime(6);not null"`
+	ToTime   time.Time `gorm:"type:datetime(6);not null"`
+
+	OldPoints string `gorm:"type:decimal(38,18);not null"`
+	NewPoints string `gorm:"type:decimal(38,18);not null"`
+	Delta     string `gorm:"type:decimal(38,18);not null"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (PointCorrection) TableName() string { return "point_correction" }
@@ -11,6 +11,9 @@ type PointRate struct {
 	RateNumerator   int64 `gorm:"not null"`
 	RateDenominator int64 `gorm:"not null"`
 
+	// 计算公式（rate_formula.id），0 = 线性：balance * num/den
+	FormulaID uint64 `gorm:"not null;default:0"`
+
 	EffectiveTime time.Time `gorm:"type:datetime(6);not null;index:uniq_rate_time,unique;index:idx_rate_time,priority:3"`
 	CreatedAt     time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
 }
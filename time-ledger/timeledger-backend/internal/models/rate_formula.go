@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RateFormula 积分计算公式（cap / 分档 / 曲线），定义见 internal/formula
+// 同一合约同名公式按 version 递增，已有版本不可修改；point_rate.formula_id 引用具体版本
+type RateFormula struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_formula_version,unique,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_formula_version,unique,priority:2"`
+	Name            string `gorm:"type:varchar(64);not null;index:uniq_formula_version,unique,priority:3"`
+	Version         int    `gorm:"not null;index:uniq_formula_version,unique,priority:4"`
+
+	Definition json.RawMessage `gorm:"type:json;not null"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (RateFormula) TableName() string { return "rate_formula" }
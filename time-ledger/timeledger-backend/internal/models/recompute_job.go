@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// 积分重算任务状态
+const (
+	RecomputePending = "pending"
+	RecomputeRunning = "running" // 进程崩溃后下次按原任务重新执行（逐账户事务，可重入）
+	RecomputeDone    = "done"
+	RecomputeFailed  = "failed" // 按 next_retry_at 退避重试，次数用尽后需手工 ledgerctl recompute
+)
+
+// RecomputeJob 积分回溯重算任务
+// 插入 / 修正的积分规则早于已结算时间时创建，重算 from_time 之后已发放的积分
+type RecomputeJob struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:idx_recompute_contract,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:idx_recompute_contract,priority:2"`
+
+	// 只重算该账户（如账户 boost 变更）；为空表示合约内全部账户
+	Account string `gorm:"type:char(42);not null;default:''"`
+
+	// 从该时间起重算（受影响规则的 effective_time）
+	FromTime time.Time `gorm:"type:datetime(6);not null"`
+
+	// 触发来源：rate_insert / manual 等；RateID 为触发的 point_rate（手工触发为 0）
+	Reason string `gorm:"type:varchar(32);not null"`
+	RateID uint64 `gorm:"not null;default:0"`
+
+	Status string `gorm:"type:varchar(16);not null;index:idx_recompute_contract,priority:3"`
+
+	Accounts    int64  `gorm:"not null;default:0"` // 重算的账户数
+	Corrected   int64  `gorm:"not null;default:0"` // 积分有变化的账户数
+	PointsDelta string `gorm:"type:decimal(38,18);not null;default:0"`
+	Error       string `gorm:"type:text"`
+
+	// 失败次数与下次重试时间（仅 failed 有效）
+	Attempts    int        `gorm:"not null;default:0"`
+	NextRetryAt *time.Time `gorm:"type:datetime(6)"`
+
+	CreatedAt  time.Time  `gorm:"type:datetime(6);not null;autoCreateTime"`
+	StartedAt  *time.Time `gorm:"type:datetime(6)"`
+	FinishedAt *time.Time `gorm:"type:datetime(6)"`
+}
+
+func (RecomputeJob) TableName() string { return "recompute_job" }
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ReorgEvent 链重组记录
+// 每次检测到分叉并回滚到 common ancestor 时写入一条，与回滚在同一事务中提交
+type ReorgEvent struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:idx_contract_time,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:idx_contract_time,priority:2"`
+
+	// 分叉点
+	AncestorBlock int64  `gorm:"not null"`
+	AncestorHash  string `gorm:"type:char(66);not null"`
+
+	// 回滚前 cursor 所在区块，及该高度上旧 / 新链的 hash
+	HeadBlock   int64  `gorm:"not null"`
+	OldHeadHash string `gorm:"type:char(66);not null"`
+	NewHeadHash string `gorm:"type:char(66);not null"`
+
+	Depth int64 `gorm:"not null"` // HeadBlock - AncestorBlock
+
+	// 被回滚的数据量
+	BalanceLogsReverted int64 `gorm:"not null;default:0"`
+	PointLogsReverted   int64 `gorm:"not null;default:0"`
+
+	DetectedAt time.Time `gorm:"type:datetime(6);not null;index:idx_contract_time,priority:3"`
+}
+
+func (ReorgEvent) TableName() string { return "reorg_event" }
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// 合约同步状态
+const (
+	SyncStateCatchingUp = "catching_up" // 正在追赶 safe block
+	SyncStateCaughtUp   = "caught_up"   // 已追上 safe block
+	SyncStateError      = "error"       // 最近一次同步失败
+	SyncStateStalled    = "stalled"     // 连续失败，已进入退避
+)
+
+// SyncStatus 每个 chain + contract 的同步状态机
+// 由 Indexer 在每次 syncContract 结束后更新
+type SyncStatus struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_chain_contract,unique"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_chain_contract,unique"`
+
+	State string `gorm:"type:varchar(16);not null;index"`
+
+	// 失败信息
+	LastError           string `gorm:"type:text"`
+	ConsecutiveFailures int    `gorm:"not null;default:0"`
+
+	LastAttemptAt time.Time  `gorm:"type:datetime(6);not null"`
+	LastSuccessAt *time.Time `gorm:"type:datetime(6)"`
+	NextRetryAt   *time.Time `gorm:"type:datetime(6)"` // 退避期内跳过该合约
+
+	// 进度与延迟
+	CursorBlock int64 `gorm:"not null;default:0"`
+	SafeBlock   int64 `gorm:"not null;default:0"`
+	LagBlocks   int64 `gorm:"not null;default:0"`
+	LagSeconds  int64 `gorm:"not null;default:0"`
+
+	UpdatedAt time.Time `gorm:"type:datetime(6);not null;autoUpdateTime"`
+}
+
+func (SyncStatus) TableName() string { return "sync_status" }
@@ -22,6 +22,10 @@ type SysChain struct {
 	ChunkSize      int `gorm:"default:10"`  // 每次扫描块数
 	RequestDelayMs int `gorm:"default:100"` // 请求间隔
 
+	// 链内合约并发
+	ContractWorkers int `gorm:"default:1"` // 并发处理的合约数
+	MaxChunksPerRun int `gorm:"default:0"` // 单合约每轮最多处理的 chunk 数（0 = 不限制）
+
 	//OP Stack 必须要用的回滚窗口
 	ReorgWindow int `gorm:"default:200"`
 
@@ -31,6 +35,25 @@ type SysChain struct {
 
 func (SysChain) TableName() string { return "sys_chains" }
 
+// 记账模式
+const (
+	AccountingModeTransfer = "transfer" // 按 Transfer 累加余额
+	AccountingModeSnapshot = "snapshot" // Transfer 仅标记账户，余额取链上 balanceOf
+)
+
+// 积分计算触发方式
+const (
+	CalcTriggerSchedule = "schedule" // 只按 calc_schedule 定时计算
+	CalcTriggerEvent    = "event"    // 另外在 indexer 推进 cursor 后尽快计算
+)
+
+// 代币标准
+const (
+	TokenStandardERC20   = "erc20"
+	TokenStandardERC721  = "erc721"  // 余额 = 持有的 NFT 数量
+	TokenStandardERC1155 = "erc1155" // 余额 = 各 tokenId 持有数量之和
+)
+
 // SysContract 合约配置表
 // 用于存储需要索引的合约。
 // 使用这张表的 ID 来命名分表。
@@ -44,9 +67,36 @@ type SysContract struct {
 	Name    string `gorm:"type:varchar(64)"`                                               // 合约别名 (如 "USDT-Pool")
 	Address string `gorm:"type:char(42);not null;index:uniq_chain_addr,unique,priority:2"` // 合约地址
 
+	// 链上元数据（indexer 首次同步时读取，owner 随 OwnershipTransferred 更新）
+	TokenName        string     `gorm:"type:varchar(128)"`
+	TokenSymbol      string     `gorm:"type:varchar(32)"`
+	Owner            string     `gorm:"type:char(42)"`
+	MetadataSyncedAt *time.Time `gorm:"type:datetime(6)"`
+
 	// 业务配置
-	StartBlock    int64 `gorm:"not null"`
-	TokenDecimals int   `gorm:"default:18"`
+	StartBlock      int64 `gorm:"not null"`           // 0 = 未配置，indexer 首次同步时自动探测（也可能是探测到的创世区块）
+	DeploymentBlock int64 `gorm:"not null;default:0"` // 自动探测到的部署区块
+	TokenDecimals   int   `gorm:"default:18"`
+	// start_block 是否已确定（配置或探测）；创世区块部署时 start_block 为 0，不能用 0 判断是否已探测
+	StartBlockResolved bool `gorm:"not null;default:false"`
+	// decimals 是否已与链上 decimals() 核对（indexer 首次同步时写入）
+	DecimalsVerified bool   `gorm:"not null;default:false"`
+	TokenStandard    string `gorm:"type:varchar(16);default:'erc20'"` // erc20 | erc721 | erc1155
+
+	// 负余额等异常的处理策略 (halt | clamp | skip)
+	AnomalyPolicy string `gorm:"type:varchar(16);default:'halt'"`
+
+	// 记账模式 (transfer | snapshot) 及 snapshot 模式的全量核对间隔（区块数，0 = 关闭）
+	AccountingMode    string `gorm:"type:varchar(16);default:'transfer'"`
+	RebaseSweepBlocks int64  `gorm:"default:0"`
+
+	// 通用事件索引：ABI 原文 + 逗号分隔的事件名（为空则不索引通用事件）
+	EventABI   string `gorm:"type:mediumtext"`
+	EventNames string `gorm:"type:varchar(1024)"`
+
+	// 积分计算调度（cron 或 @every，空 = @hourly）与触发方式
+	CalcSchedule string `gorm:"type:varchar(64)"`
+	CalcTrigger  string `gorm:"type:varchar(16);default:'schedule'"`
 
 	// 状态开关 (方便单独暂停某个合约的索引/计算)
 	IsEnabled bool `gorm:"default:true;index"`
@@ -61,6 +111,24 @@ func (SysContract) TableName() string { return "sys_contracts" }
 // 辅助函数：生成动态表名
 // ---------------------------------------------------------
 
+// IsNFT 是否为 NFT 合约（ERC-721 / ERC-1155）
+func (c *SysContract) IsNFT() bool {
+	return c.TokenStandard == TokenStandardERC721 || c.TokenStandard == TokenStandardERC1155
+}
+
+// HasStartBlock start_block 是否已确定（配置了或已自动探测）
+func (c *SysContract) HasStartBlock() bool {
+	return c.StartBlock > 0 || c.StartBlockResolved
+}
+
+// BalanceDecimals 余额换算精度：NFT 按个数计，精度固定为 0
+func (c *SysContract) BalanceDecimals() int32 {
+	if c.IsNFT() {
+		return 0
+	}
+	return int32(c.TokenDecimals)
+}
+
 // GetLogTableName 根据合约 ID 生成唯一的积分流水表名
 // 例如 ID=5 -> "user_point_log_5"
 func (c *SysContract) GetLogTableName() string {
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// TokenAllowance 当前授权快照（owner -> spender）
+// 仅由 Approval 事件驱动：OpenZeppelin v5 的 transferFrom 扣减授权时不发 Approval，
+// 因此这里是“最近一次授权额度”，代表授权敞口上限
+type TokenAllowance struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_allowance,unique,priority:1;index:idx_spender,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_allowance,unique,priority:2;index:idx_spender,priority:2"`
+	Owner           string `gorm:"type:char(42);not null;index:uniq_allowance,unique,priority:3"`
+	Spender         string `gorm:"type:char(42);not null;index:uniq_allowance,unique,priority:4;index:idx_spender,priority:3"`
+
+	Allowance string `gorm:"type:varchar(78);not null"`
+	Unlimited bool   `gorm:"not null;default:false"` // allowance == 2^256-1
+
+	// 最近一次 Approval 的位置
+	BlockNumber int64     `gorm:"not null"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+	TxHash      string    `gorm:"type:char(66);not null"`
+	LogIndex    int64     `gorm:"not null"`
+
+	UpdatedAt time.Time `gorm:"type:datetime(6);not null;autoUpdateTime"`
+}
+
+func (TokenAllowance) TableName() string { return "token_allowance" }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// TokenHolding NFT 当前持仓快照（account + tokenId）
+// 仅 ERC-721 / ERC-1155 合约写入；数量归零即删除
+// 积分仍按 user_balance（持有总数）计算，这里用于按 tokenId 查询持有人
+type TokenHolding struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_holding,unique,priority:1;index:idx_token,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_holding,unique,priority:2;index:idx_token,priority:2"`
+	Account         string `gorm:"type:char(42);not null;index:uniq_holding,unique,priority:3"`
+	TokenID         string `gorm:"type:varchar(78);not null;index:uniq_holding,unique,priority:4;index:idx_token,priority:3"`
+
+	Amount string `gorm:"type:decimal(65,0);not null"`
+
+	BlockNumber int64     `gorm:"not null"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+
+	UpdatedAt time.Time `gorm:"type:datetime(6);not null;autoUpdateTime"`
+}
+
+func (TokenHolding) TableName() string { return "token_holding" }
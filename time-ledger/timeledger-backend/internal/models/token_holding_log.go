@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// TokenHoldingLog NFT 持仓变动流水（account + tokenId 维度）
+// token_holding 由它派生，reorg 回滚后据此重建
+type TokenHoldingLog struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_holding_log,unique,priority:1;index:idx_block,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_holding_log,unique,priority:2;index:idx_block,priority:2"`
+	Account         string `gorm:"type:char(42);not null;index:uniq_holding_log,unique,priority:3"`
+	TokenID         string `gorm:"type:varchar(78);not null;index:uniq_holding_log,unique,priority:4"`
+
+	Delta       string `gorm:"type:decimal(65,0);not null"`
+	AmountAfter string `gorm:"type:decimal(65,0);not null"`
+
+	BlockNumber int64     `gorm:"not null;index:uniq_holding_log,unique,priority:5;index:idx_block,priority:3"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+	TxHash      string    `gorm:"type:char(66);not null"`
+	LogIndex    int64     `gorm:"not null;index:uniq_holding_log,unique,priority:6"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (TokenHoldingLog) TableName() string { return "token_holding_log" }
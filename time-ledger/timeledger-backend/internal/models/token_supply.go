@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// TokenSupply 供应量历史（每个发生 mint / burn 的区块一行）
+// - minted / burned 为该区块内的铸造、销毁总量
+// - total_supply 从 start_block 开始按 mint - burn 累计（start_block 之后部署的合约即为真实总量）
+// - holder_count 为该区块结束时余额 > 0 的账户数
+// - snapshot 记账的 rebase 代币，total_supply 不包含 rebase 的变化
+type TokenSupply struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_supply,unique,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_supply,unique,priority:2"`
+
+	BlockNumber int64     `gorm:"not null;index:uniq_supply,unique,priority:3"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+
+	Minted      string `gorm:"type:decimal(65,0);not null"`
+	Burned      string `gorm:"type:decimal(65,0);not null"`
+	TotalSupply string `gorm:"type:decimal(65,0);not null"`
+	HolderCount int64  `gorm:"not null"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (TokenSupply) TableName() string { return "token_supply" }
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Transfer 类型
+const (
+	TransferKindMint     = "mint"     // from = 0x0
+	TransferKindBurn     = "burn"     // to = 0x0
+	TransferKindTransfer = "transfer" // 普通转账
+)
+
+// TransferEvent 原始 Transfer 事件流水
+// ERC-1155 的 TransferSingle / TransferBatch 同样记为一行
+// 每个链上 log 一行，与 balance_log（每个账户一行）在同一个 chunk 事务中写入
+type TransferEvent struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+
+	ChainID         int64  `gorm:"not null;index:uniq_transfer,unique,priority:1;index:idx_from,priority:1;index:idx_to,priority:1;index:idx_tx,priority:1"`
+	ContractAddress string `gorm:"type:char(42);not null;index:uniq_transfer,unique,priority:2;index:idx_from,priority:2;index:idx_to,priority:2;index:idx_tx,priority:2"`
+
+	BlockNumber int64     `gorm:"not null;index:uniq_transfer,unique,priority:3;index:idx_from,priority:4;index:idx_to,priority:4"`
+	BlockTime   time.Time `gorm:"type:datetime(6);not null"`
+
+	TxHash   string `gorm:"type:char(66);not null;index:idx_tx,priority:3"`
+	LogIndex int64  `gorm:"not null;index:uniq_transfer,unique,priority:4"`
+
+	FromAddress string `gorm:"type:char(42);not null;index:idx_from,priority:3"`
+	ToAddress   string `gorm:"type:char(42);not null;index:idx_to,priority:3"`
+	Value       string `gorm:"type:decimal(65,0);not null"`
+
+	Kind string `gorm:"type:varchar(16);not null"` // mint | burn | transfer
+
+	// NFT：本次转移的 tokenId 及对应数量（逗号分隔的十进制），value 为数量之和
+	// ERC-721 数量恒为 1；ERC20 为空
+	TokenIDs     string `gorm:"type:text"`
+	TokenAmounts string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
+}
+
+func (TransferEvent) TableName() string { return "transfer_event" }
@@ -22,8 +22,12 @@ type UserPointLog struct {
 	Points string `gorm:"type:decimal(38,18);not null"`
 
 	// 本次计算使用的积分规则
-	RateNumerator   int64 `gorm:"not null"`
-	RateDenominator int64 `gorm:"not null"`
+	RateNumerator   int64  `gorm:"not null"`
+	RateDenominator int64  `gorm:"not null"`
+	FormulaID       uint64 `gorm:"not null;default:0"` // rate_formula.id，0 = 线性
+
+	// 本区间生效的账户倍率（point_boost 乘积），无 boost 为 1
+	BoostMultiplier string `gorm:"type:decimal(20,8);not null;default:1"`
 
 	CreatedAt time.Time `gorm:"type:datetime(6);not null;autoCreateTime"`
 }
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// ErrLeaseLost 租约已被其他实例接管（或已释放）
+var ErrLeaseLost = errors.New("lease lost")
+
+// Lease 当前实例持有的一份租约
+type Lease struct {
+	Name      string
+	Owner     string
+	Token     int64
+	ExpiresAt time.Time
+}
+
+// LeaseRepository 基于 job_lease 表的租约存取接口
+// 时间以各实例本地 UTC 时间为准，TTL 需远大于实例间时钟偏差
+type LeaseRepository interface {
+	// 尝试获取租约：无人持有 / 已过期 / 本实例持有 时成功
+	TryAcquire(
+		ctx context.Context,
+		name, owner string,
+		ttl time.Duration,
+	) (*Lease, bool, error)
+
+	// 续约，租约已易主时返回 ErrLeaseLost
+	Renew(
+		ctx context.Context,
+		lease *Lease,
+		ttl time.Duration,
+	) error
+
+	// 主动释放（置为立即过期）
+	Release(
+		ctx context.Context,
+		lease *Lease,
+	) error
+
+	// 在写事务内校验 fencing token，租约已易主时返回 ErrLeaseLost
+	CheckFence(
+		tx *gorm.DB,
+		lease *Lease,
+	) error
+}
+
+// leaseRepo 是基于 GORM 的实现
+type leaseRepo struct {
+	db *gorm.DB
+}
+
+// NewLeaseRepo 创建 LeaseRepository 实例
+func NewLeaseRepo(db *gorm.DB) LeaseRepository {
+	return &leaseRepo{db: db}
+}
+
+// TryAcquire 获取租约
+// - 不存在：插入，token = 1
+// - 本实例持有且未过期：续期，token 不变
+// - 已过期：接管，token + 1
+// - 其他实例持有且未过期：返回 false
+func (r *leaseRepo) TryAcquire(
+	ctx context.Context,
+	name, owner string,
+	ttl time.Duration,
+) (*Lease, bool, error) {
+
+	var out *Lease
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		var row models.JobLease
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("name=?", name).
+			First(&row).Error
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			row = models.JobLease{
+				Name:         name,
+				Owner:        owner,
+				FencingToken: 1,
+				ExpiresAt:    now.Add(ttl),
+				UpdatedAt:    now,
+			}
+
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+			if res.Error != nil {
+				return res.Error
+			}
+			// 并发插入，被其他实例抢先
+			if res.RowsAffected == 0 {
+				return nil
+			}
+
+			out = leaseFromRow(row)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case row.Owner == owner && row.ExpiresAt.After(now):
+			// 本实例持有，续期
+		case !row.ExpiresAt.After(now):
+			// 已过期，接管
+			row.Owner = owner
+			row.FencingToken++
+		default:
+			// 其他实例持有
+			return nil
+		}
+
+		row.ExpiresAt = now.Add(ttl)
+
+		if err := tx.Model(&models.JobLease{}).
+			Where("id=?", row.ID).
+			Updates(map[string]any{
+				"owner":         row.Owner,
+				"fencing_token": row.FencingToken,
+				"expires_at":    row.ExpiresAt,
+				"updated_at":    now,
+			}).Error; err != nil {
+			return err
+		}
+
+		out = leaseFromRow(row)
+		return nil
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, out != nil, nil
+}
+
+// Renew 续约
+// 只要 owner + token 未变就允许续期（即使刚过期但尚未被接管）
+func (r *leaseRepo) Renew(
+	ctx context.Context,
+	lease *Lease,
+	ttl time.Duration,
+) error {
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+
+	res := r.db.WithContext(ctx).
+		Model(&models.JobLease{}).
+		Where(
+			"name=? AND owner=? AND fencing_token=?",
+			lease.Name, lease.Owner, lease.Token,
+		).
+		Updates(map[string]any{
+			"expires_at": expiresAt,
+			"updated_at": now,
+		})
+
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+
+	lease.ExpiresAt = expiresAt
+	return nil
+}
+
+// Release 主动释放租约
+func (r *leaseRepo) Release(
+	ctx context.Context,
+	lease *Lease,
+) error {
+
+	now := time.Now().UTC()
+
+	return r.db.WithContext(ctx).
+		Model(&models.JobLease{}).
+		Where(
+			"name=? AND owner=? AND fencing_token=?",
+			lease.Name, lease.Owner, lease.Token,
+		).
+		Updates(map[string]any{
+			"expires_at": now,
+			"updated_at": now,
+		}).Error
+}
+
+// CheckFence 在写事务内校验 fencing token
+// 使用共享锁：接管方的 FOR UPDATE 会等待本事务提交，避免新旧持有者交错写入
+func (r *leaseRepo) CheckFence(
+	tx *gorm.DB,
+	lease *Lease,
+) error {
+
+	var row models.JobLease
+	err := tx.
+		Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("name=?", lease.Name).
+		First(&row).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+
+	if row.Owner != lease.Owner || row.FencingToken != lease.Token {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func leaseFromRow(row models.JobLease) *Lease {
+	return &Lease{
+		Name:      row.Name,
+		Owner:     row.Owner,
+		Token:     row.FencingToken,
+		ExpiresAt: row.ExpiresAt,
+	}
+}
@@ -127,11 +127,28 @@ func (r *pointRateRepo) ListAll(
 }
 
 // Create 新增一条积分规则
-// 注意：不做任何冲突检测或业务校验，由上层 service 保证
+// 注意：不做冲突检测或业务校验，由上层 service（service/rates）保证
+// effective_time 早于已结算时间时，在同一事务中创建回溯重算任务（recompute_job）
 func (r *pointRateRepo) Create(
 	ctx context.Context,
 	rate *models.PointRate,
 ) error {
 
-	return r.db.WithContext(ctx).Create(rate).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rate).Error; err != nil {
+			return err
+		}
+
+		_, err := EnqueueRecompute(
+			ctx,
+			tx,
+			rate.ChainID,
+			rate.ContractAddress,
+			"",
+			rate.EffectiveTime,
+			RecomputeReasonRateInsert,
+			rate.ID,
+		)
+		return err
+	})
 }
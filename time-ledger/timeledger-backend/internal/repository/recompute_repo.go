@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+// 重算任务触发来源
+const (
+	RecomputeReasonRateInsert = "rate_insert"
+	RecomputeReasonRateCancel = "rate_cancel"
+	RecomputeReasonManual     = "manual"
+	RecomputeReasonBoost      = "boost"
+)
+
+// recomputeClockSkew 区块时间可能略超前于本机时间，判断是否需要重算时放宽的上界
+const recomputeClockSkew = 5 * time.Minute
+
+// EnqueueRecompute 当 from 早于该合约可能已结算到的最晚时间时创建重算任务
+// 上界取 max(已提交的 last_calc_time, now + recomputeClockSkew)：进行中的计算可能按旧规则提交超过 from 的积分，
+// 只比较已提交的 last_calc_time 会漏掉它们；多创建的任务执行时没有受影响账户，代价很小
+// account 非空时只重算该账户（比较的也是该账户的 last_calc_time），为空表示全部账户
+// 已有同范围未开始的任务时合并（取更早的 from_time），不重复创建；无需重算时返回 nil
+func EnqueueRecompute(
+	ctx context.Context,
+	tx *gorm.DB,
+	chainID int64,
+	contract, account string,
+	from time.Time,
+	reason string,
+	rateID uint64,
+) (*models.RecomputeJob, error) {
+
+	from = from.UTC()
+
+	q := tx.WithContext(ctx).
+		Model(&models.UserPoint{}).
+		Select("MAX(last_calc_time) AS last_calc_time").
+		Where("chain_id=? AND contract_address=?", chainID, contract)
+	if account != "" {
+		q = q.Where("account=?", account)
+	}
+
+	var latest struct{ LastCalcTime *time.Time }
+	if err := q.Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+	bound := time.Now().UTC().Add(recomputeClockSkew)
+	if latest.LastCalcTime != nil && latest.LastCalcTime.UTC().After(bound) {
+		bound = latest.LastCalcTime.UTC()
+	}
+	if !from.Before(bound) {
+		return nil, nil
+	}
+
+	var job models.RecomputeJob
+	if err := tx.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND account=? AND status=?",
+			chainID, contract, account, models.RecomputePending,
+		).
+		Order("id ASC").
+		Limit(1).
+		Find(&job).Error; err != nil {
+		return nil, err
+	}
+
+	if job.ID != 0 {
+		if from.Before(job.FromTime.UTC()) {
+			if err := tx.WithContext(ctx).
+				Model(&models.RecomputeJob{}).
+				Where("id=?", job.ID).
+				Updates(map[string]any{
+					"from_time": from,
+				}).Error; err != nil {
+				return nil, err
+			}
+			job.FromTime = from
+		}
+		return &job, nil
+	}
+
+	job = models.RecomputeJob{
+		ChainID:         chainID,
+		ContractAddress: contract,
+		Account:         account,
+		FromTime:        from,
+		Reason:          reason,
+		RateID:          rateID,
+		Status:          models.RecomputePending,
+		PointsDelta:     "0",
+	}
+	if err := tx.WithContext(ctx).Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -30,8 +31,27 @@ func InitSystem(ctx context.Context, db *gorm.DB, cfg *config.Config) error {
 		&models.BlockCursor{},
 		&models.BlockHeader{},
 		&models.BalanceLog{},
+		&models.TransferEvent{},
+		&models.ContractEvent{},
+		&models.ApprovalLog{},
+		&models.TokenAllowance{},
+		&models.OwnershipHistory{},
+		&models.TokenHolding{},
+		&models.TokenHoldingLog{},
+		&models.TokenSupply{},
 		&models.UserBalance{},
 		&models.UserPoint{},
+		&models.IndexerAnomaly{},
+		&models.ReorgEvent{},
+		&models.PendingBlock{},
+		&models.SyncStatus{},
+		&models.JobLease{},
+		&models.CalcShard{},
+		&models.CalcRun{},
+		&models.RecomputeJob{},
+		&models.PointCorrection{},
+		&models.RateFormula{},
+		&models.PointBoost{},
 		// 注意：不包含 UserPointLog，因为它是动态表
 	}
 
@@ -39,6 +59,13 @@ func InitSystem(ctx context.Context, db *gorm.DB, cfg *config.Config) error {
 		return fmt.Errorf("数据库表结构迁移失败: %w", err)
 	}
 
+	// indexer_anomaly 唯一索引加入 kind / token_id 后改名为 uniq_anomaly_event，删除旧索引
+	if db.Migrator().HasIndex(&models.IndexerAnomaly{}, "uniq_anomaly") {
+		if err := db.Migrator().DropIndex(&models.IndexerAnomaly{}, "uniq_anomaly"); err != nil {
+			return fmt.Errorf("删除旧索引 uniq_anomaly 失败: %w", err)
+		}
+	}
+
 	// ---------------------------------------------------------
 	// 2. 数据初始化与动态建表
 	// ---------------------------------------------------------
@@ -62,26 +89,72 @@ func syncSysConfig(ctx context.Context, db *gorm.DB, cfg *config.Config) error {
 			ReorgWindow:    int(chainCfg.ReorgWindow),
 			ChunkSize:      int(chainCfg.ChunkSize),
 			RequestDelayMs: int(chainCfg.RequestDelayMs),
+
+			ContractWorkers: chainCfg.ContractWorkers,
+			MaxChunksPerRun: chainCfg.MaxChunksPerRun,
 		}
 
 		if err := db.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "chain_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"name", "type", "reorg_window", "chunk_size", "request_delay_ms"}),
+			Columns: []clause.Column{{Name: "chain_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"name", "type", "reorg_window", "chunk_size", "request_delay_ms",
+				"contract_workers", "max_chunks_per_run",
+			}),
 		}).Create(&sysChain).Error; err != nil {
 			return fmt.Errorf("同步 Chain %d 失败: %w", chainCfg.ChainID, err)
 		}
 
 		// --- Sync Contracts ---
 		for _, contractCfg := range chainCfg.Contracts {
+			anomalyPolicy := contractCfg.AnomalyPolicy
+			if anomalyPolicy == "" {
+				anomalyPolicy = models.AnomalyPolicyHalt
+			}
+
+			accountingMode := contractCfg.AccountingMode
+			if accountingMode == "" {
+				accountingMode = models.AccountingModeTransfer
+			}
+
+			// token_decimals 只是覆盖值：未配置时新合约先按 18 占位，由 indexer 读取链上 decimals() 后修正
+			tokenDecimals := int(contractCfg.TokenDecimals)
+			if tokenDecimals == 0 {
+				tokenDecimals = 18
+			}
+
+			tokenStandard := contractCfg.TokenStandard
+			if tokenStandard == "" {
+				tokenStandard = models.TokenStandardERC20
+			}
+
+			calcTrigger := contractCfg.CalcTrigger
+			if calcTrigger == "" {
+				calcTrigger = models.CalcTriggerSchedule
+			}
+
 			// 1. 准备数据
 			sysContract := models.SysContract{
 				ChainID:       chainCfg.ChainID,
 				Address:       contractCfg.Address,
 				StartBlock:    contractCfg.StartBlock,
-				TokenDecimals: int(contractCfg.TokenDecimals),
-				Name:          "Default-Pool",
-				IsEnabled:     true,
-				CreatedAt:     time.Now().UTC(),
+				TokenDecimals: tokenDecimals,
+				TokenStandard: tokenStandard,
+				AnomalyPolicy: anomalyPolicy,
+
+				AccountingMode:    accountingMode,
+				RebaseSweepBlocks: contractCfg.RebaseSweepBlocks,
+
+				StartBlockResolved: contractCfg.StartBlock > 0,
+
+				EventABI:   contractCfg.ABIJSON,
+				EventNames: strings.Join(contractCfg.Events, ","),
+
+				CalcSchedule: contractCfg.CalcSchedule,
+				CalcTrigger:  calcTrigger,
+
+				Name:      contractCfg.Name,
+				IsEnabled: true,
+				CreatedAt: time.Now().UTC(),
 			}
 
 			// 2. Upsert (我们需要拿到 ID)
@@ -92,11 +165,40 @@ func syncSysConfig(ctx context.Context, db *gorm.DB, cfg *config.Config) error {
 			if err == nil {
 				// 存在：更新
 				sysContract.ID = existing.ID
-				if err := db.Model(&existing).Updates(map[string]interface{}{
-					"start_block":    contractCfg.StartBlock,
-					"token_decimals": int(contractCfg.TokenDecimals),
-					"is_enabled":     true,
-				}).Error; err != nil {
+				updates := map[string]interface{}{
+					"token_standard": tokenStandard,
+					"anomaly_policy": anomalyPolicy,
+
+					"accounting_mode":     accountingMode,
+					"rebase_sweep_blocks": contractCfg.RebaseSweepBlocks,
+
+					"event_abi":   contractCfg.ABIJSON,
+					"event_names": strings.Join(contractCfg.Events, ","),
+
+					"calc_schedule": contractCfg.CalcSchedule,
+					"calc_trigger":  calcTrigger,
+
+					"is_enabled": true,
+				}
+
+				// start_block 未配置时保留库中已有值（可能已由 indexer 自动探测）
+				if contractCfg.StartBlock > 0 {
+					updates["start_block"] = contractCfg.StartBlock
+					updates["start_block_resolved"] = true
+				}
+
+				// 别名未配置时保留库中已有值（可能已由 indexer 用 symbol 填充）
+				if contractCfg.Name != "" {
+					updates["name"] = contractCfg.Name
+				}
+
+				// 不覆盖已从链上核对过的 decimals；配置了不同的覆盖值则重新核对
+				if contractCfg.TokenDecimals > 0 && int(contractCfg.TokenDecimals) != existing.TokenDecimals {
+					updates["token_decimals"] = int(contractCfg.TokenDecimals)
+					updates["decimals_verified"] = false
+				}
+
+				if err := db.Model(&existing).Updates(updates).Error; err != nil {
 					return err
 				}
 			} else {
@@ -111,17 +213,18 @@ func syncSysConfig(ctx context.Context, db *gorm.DB, cfg *config.Config) error {
 			// -------------------------------------------------------
 			logTableName := sysContract.GetLogTableName() // 获取表名，如 user_point_log_1
 
-			// 检查表是否存在，不存在则创建
+			// 不存在则创建；已存在也 AutoMigrate，补齐新增字段（如 formula_id）
 			if !db.Migrator().HasTable(logTableName) {
 				log.Printf("[Init] 正在创建动态分表: %s", logTableName)
-				// 使用 UserPointLog 结构体做模板，但指定 TableName
-				if err := db.Table(logTableName).AutoMigrate(&models.UserPointLog{}); err != nil {
-					return fmt.Errorf("create dynamic table %s failed: %w", logTableName, err)
-				}
+			}
+			// 使用 UserPointLog 结构体做模板，但指定 TableName
+			if err := db.Table(logTableName).AutoMigrate(&models.UserPointLog{}); err != nil {
+				return fmt.Errorf("migrate dynamic table %s failed: %w", logTableName, err)
 			}
 
 			// 4. 初始化默认积分规则 (如果是新合约)
-			if err := initDefaultRates(db, sysContract); err != nil {
+			num, den := contractCfg.InitialRate(cfg.Points)
+			if err := initDefaultRates(db, sysContract, num, den); err != nil {
 				log.Printf("[Warn] init default rate failed: %v", err)
 			}
 		}
@@ -130,8 +233,12 @@ func syncSysConfig(ctx context.Context, db *gorm.DB, cfg *config.Config) error {
 	return nil
 }
 
-// initDefaultRates 确保默认积分规则存在
-func initDefaultRates(db *gorm.DB, contract models.SysContract) error {
+// initDefaultRates 确保默认积分规则存在（规则来自配置，只在合约没有任何规则时写入）
+func initDefaultRates(
+	db *gorm.DB,
+	contract models.SysContract,
+	numerator, denominator int64,
+) error {
 	var count int64
 	db.Model(&models.PointRate{}).
 		Where("chain_id = ? AND contract_address = ?", contract.ChainID, contract.Address).
@@ -143,8 +250,8 @@ func initDefaultRates(db *gorm.DB, contract models.SysContract) error {
 			ChainID:         contract.ChainID,
 			ContractAddress: contract.Address,
 			EffectiveTime:   time.Unix(0, 0).UTC(),
-			RateNumerator:   5,
-			RateDenominator: 100,
+			RateNumerator:   numerator,
+			RateDenominator: denominator,
 			CreatedAt:       time.Now().UTC(),
 		}
 		return db.Create(&rate).Error
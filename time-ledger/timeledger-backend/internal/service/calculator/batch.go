@@ -0,0 +1,325 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Batch
+-----
+- 缺失的 user_point 用一条 INSERT ... SELECT 补齐，初始 last_calc_time 为账户首次出现时间
+- idle 账户：自 last_calc_time 起没有余额变化（balance_log.block_time >= last_calc_time 不存在），
+  且当前 rate 在 last_calc_time 之前已生效。此时 [last_calc_time, now) 只有一段，
+  余额即 user_balance.balance，积分 = balance * rate * seconds / 3600，整批用 SQL 完成：
+  1. INSERT ... SELECT 写入分表日志（积分为 0 的不写）
+  2. UPDATE ... JOIN 分表日志累加 total_points 并推进 last_calc_time
+  3. 余额为 0（或 rate 为 0）的 idle 账户只推进 last_calc_time
+- 其余账户（以及上面因舍入等原因未推进的账户）仍走 calcOneAccount 的分段计算
+- 与 pointsForSegment 一致：秒数向下取整，积分保留 16 位小数（四舍五入）
+- 当前 rate 带公式（formula_id != 0）时不走批量路径
+- [last_calc_time, now) 内有 point_boost 生效的账户不算 idle，走分段计算
+- 超出列宽的账户不写日志，留给分段计算：
+  - 原始余额超过 35 位整数时 CAST 到 DECIMAL(65,30) 会溢出
+  - user_point_log.balance / points 为 decimal(38,18)，展示余额和积分最多 20 位整数
+- 日志用 ON DUPLICATE KEY UPDATE 去重而不是 INSERT IGNORE：IGNORE 会把越界值截断成最大值并只给 warning，
+  越界时必须整批失败退回分段计算
+*/
+
+// batchMaxDecimals 超过该精度的合约不走批量路径（MySQL DECIMAL 最多 30 位小数）
+const batchMaxDecimals = 30
+
+// batchCastMaxDigits DECIMAL(65,30) 最多容纳的整数位数
+const batchCastMaxDigits = 65 - batchMaxDecimals
+
+// batchLogMaxDigits user_point_log.balance / points（decimal(38,18)）最多容纳的整数位数
+const batchLogMaxDigits = 38 - 18
+
+// idleBalanceMaxDigits 批量路径允许的原始余额（wei）最大位数：
+// 展示余额不超过日志列宽，且原始值可以 CAST 到 DECIMAL(65,30)
+func idleBalanceMaxDigits(decimals int32) int {
+	return min(batchLogMaxDigits+int(decimals), batchCastMaxDigits)
+}
+
+// idleAccountsWhere idle 账户条件（up / ub 别名）再加上 balanceCond，
+// 参数：chainID, addr, t1, rate.EffectiveTime, t1
+func idleAccountsWhere(balanceCond string) string {
+	return fmt.Sprintf(`
+		up.chain_id = ? AND up.contract_address = ?
+		AND up.last_calc_time < ?
+		AND up.last_calc_time >= ?
+		AND NOT EXISTS (
+			SELECT 1 FROM %s bl
+			WHERE bl.chain_id = up.chain_id AND bl.contract_address = up.contract_address
+				AND bl.account = up.account AND bl.block_time >= up.last_calc_time
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM %s pb
+			WHERE pb.chain_id = up.chain_id AND pb.contract_address = up.contract_address
+				AND pb.account = up.account AND pb.start_time < ?
+				AND (pb.end_time IS NULL OR pb.end_time > up.last_calc_time)
+		)
+		AND %s`,
+		models.BalanceLog{}.TableName(),
+		models.PointBoost{}.TableName(),
+		balanceCond,
+	)
+}
+
+// accruingBalanceCond 产生积分且不超出列宽的余额条件
+func accruingBalanceCond(decimals int32) string {
+	return fmt.Sprintf("ub.balance > 0 AND LENGTH(ub.balance) <= %d", idleBalanceMaxDigits(decimals))
+}
+
+// ensureUserPoints 为出现过但还没有 user_point 的账户补建记录，返回新增行数
+func (s *Service) ensureUserPoints(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	fallback time.Time,
+) (int64, error) {
+
+	nowUTC := time.Now().UTC()
+
+	sql := fmt.Sprintf(`
+		INSERT IGNORE INTO %s (chain_id, contract_address, account, total_points, last_calc_time, updated_at)
+		SELECT ub.chain_id, ub.contract_address, ub.account, 0, COALESCE(fs.t, ?), ?
+		FROM %s ub
+		LEFT JOIN (
+			SELECT account, MIN(block_time) AS t
+			FROM %s
+			WHERE chain_id = ? AND contract_address = ?
+			GROUP BY account
+		) fs ON fs.account = ub.account
+		LEFT JOIN %s up
+			ON up.chain_id = ub.chain_id AND up.contract_address = ub.contract_address AND up.account = ub.account
+		WHERE ub.chain_id = ? AND ub.contract_address = ? AND up.id IS NULL`,
+		models.UserPoint{}.TableName(),
+		models.UserBalance{}.TableName(),
+		models.BalanceLog{}.TableName(),
+		models.UserPoint{}.TableName(),
+	)
+
+	res := s.db.WithContext(ctx).Exec(
+		sql,
+		fallback.UTC(), nowUTC,
+		chainID, contract,
+		chainID, contract,
+	)
+	return res.RowsAffected, res.Error
+}
+
+// currentRate now 时刻生效的 rate；没有任何 rate 时返回 ok = false
+func (s *Service) currentRate(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	now time.Time,
+) (models.PointRate, bool, error) {
+
+	var pr models.PointRate
+	err := s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND effective_time <= ?",
+			chainID, contract, now,
+		).
+		Order("effective_time DESC").
+		Limit(1).
+		Find(&pr).Error
+	if err != nil {
+		return pr, false, err
+	}
+	return pr, pr.ID != 0 && pr.RateDenominator != 0, nil
+}
+
+// accrueIdle 批量结算 idle 账户，返回推进了 last_calc_time 的账户数及发放的积分
+func (s *Service) accrueIdle(
+	ctx context.Context,
+	contract models.SysContract,
+	decimals int32,
+	now time.Time,
+	logTableName string,
+) (int64, decimal.Decimal, error) {
+
+	if decimals > batchMaxDecimals {
+		return 0, decimal.Zero, nil
+	}
+
+	chainID := contract.ChainID
+	addr := contract.Address
+	t1 := now.UTC()
+
+	rate, ok, err := s.currentRate(ctx, chainID, addr, t1)
+	if err != nil || !ok {
+		return 0, decimal.Zero, err
+	}
+	// 非线性公式无法用 SQL 表达，全部走分段计算
+	if rate.FormulaID != 0 {
+		return 0, decimal.Zero, nil
+	}
+
+	rateDec := decimal.NewFromInt(rate.RateNumerator).Div(decimal.NewFromInt(rate.RateDenominator))
+	scale := decimal.New(1, decimals).String()
+
+	idleArgs := []any{chainID, addr, t1, rate.EffectiveTime, t1}
+
+	joinBalance := fmt.Sprintf(
+		"JOIN %s ub ON ub.chain_id = up.chain_id AND ub.contract_address = up.contract_address AND ub.account = up.account",
+		models.UserBalance{}.TableName(),
+	)
+
+	var (
+		advanced int64
+		issued   = decimal.Zero
+	)
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+
+		// fencing：租约已易主则拒绝写入
+		if err := s.leases.CheckFence(ctx, tx); err != nil {
+			return err
+		}
+
+		nowUTC := time.Now().UTC()
+
+		if rateDec.GreaterThan(decimal.Zero) {
+			// 1) 分表日志：一段 [last_calc_time, t1)
+			insertLog := fmt.Sprintf(`
+				INSERT INTO %s
+					(chain_id, contract_address, account, balance, from_time, to_time,
+					 points, rate_numerator, rate_denominator, created_at)
+				SELECT chain_id, contract_address, account, balance, from_time, ?, points, ?, ?, ?
+				FROM (
+					SELECT
+						up.chain_id, up.contract_address, up.account,
+						CAST(ub.balance AS DECIMAL(65,30)) / CAST(? AS DECIMAL(65,0)) AS balance,
+						up.last_calc_time AS from_time,
+						ROUND(
+							CAST(ub.balance AS DECIMAL(65,30)) / CAST(? AS DECIMAL(65,0))
+								* CAST(? AS DECIMAL(65,30))
+								* TIMESTAMPDIFF(SECOND, up.last_calc_time, ?)
+								/ 3600,
+							16
+						) AS points
+					FROM %s up
+					%s
+					WHERE %s
+				) x
+				WHERE x.points > 0 AND x.points < %s
+				ON DUPLICATE KEY UPDATE id = id`,
+				logTableName,
+				models.UserPoint{}.TableName(),
+				joinBalance,
+				idleAccountsWhere(accruingBalanceCond(decimals)),
+				decimal.New(1, batchLogMaxDigits).String(),
+			)
+
+			args := []any{
+				t1, rate.RateNumerator, rate.RateDenominator, nowUTC,
+				scale, scale, rateDec.String(), t1,
+			}
+			args = append(args, idleArgs...)
+
+			if err := tx.Exec(insertLog, args...).Error; err != nil {
+				return fmt.Errorf("insert idle point logs: %w", err)
+			}
+
+			// 2) 按刚写入的日志累加（from_time = last_calc_time 保证每段只累加一次）
+			logJoin := fmt.Sprintf(`
+				JOIN %s l
+					ON l.chain_id = up.chain_id AND l.contract_address = up.contract_address
+					AND l.account = up.account AND l.from_time = up.last_calc_time AND l.to_time = ?`,
+				logTableName,
+			)
+
+			var sum struct{ Points decimal.NullDecimal }
+			sumLog := fmt.Sprintf(`
+				SELECT SUM(l.points) AS points
+				FROM %s up
+				%s
+				WHERE up.chain_id = ? AND up.contract_address = ? AND up.last_calc_time < ?`,
+				models.UserPoint{}.TableName(),
+				logJoin,
+			)
+			if err := tx.Raw(sumLog, t1, chainID, addr, t1).Scan(&sum).Error; err != nil {
+				return fmt.Errorf("sum idle point logs: %w", err)
+			}
+			if sum.Points.Valid {
+				issued = sum.Points.Decimal
+			}
+
+			applyLog := fmt.Sprintf(`
+				UPDATE %s up
+				%s
+				SET up.total_points = up.total_points + l.points,
+					up.last_calc_time = ?,
+					up.updated_at = ?
+				WHERE up.chain_id = ? AND up.contract_address = ? AND up.last_calc_time < ?`,
+				models.UserPoint{}.TableName(),
+				logJoin,
+			)
+
+			res := tx.Exec(applyLog, t1, t1, nowUTC, chainID, addr, t1)
+			if res.Error != nil {
+				return fmt.Errorf("apply idle point logs: %w", res.Error)
+			}
+			advanced += res.RowsAffected
+		}
+
+		// 3) 不产生积分的 idle 账户只推进时间
+		zeroCond := "ub.balance = 0"
+		if !rateDec.GreaterThan(decimal.Zero) {
+			zeroCond = "1 = 1"
+		}
+
+		advance := fmt.Sprintf(`
+			UPDATE %s up
+			%s
+			SET up.last_calc_time = ?, up.updated_at = ?
+			WHERE %s`,
+			models.UserPoint{}.TableName(),
+			joinBalance,
+			idleAccountsWhere(zeroCond),
+		)
+
+		args := append([]any{t1, nowUTC}, idleArgs...)
+		res := tx.Exec(advance, args...)
+		if res.Error != nil {
+			return fmt.Errorf("advance idle accounts: %w", res.Error)
+		}
+		advanced += res.RowsAffected
+
+		return nil
+	})
+	if err != nil {
+		return 0, decimal.Zero, err
+	}
+
+	return advanced, issued, nil
+}
+
+// pendingAccounts last_calc_time 仍早于 now 的账户（需要分段计算）
+func (s *Service) pendingAccounts(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	now time.Time,
+) ([]string, error) {
+
+	var accounts []string
+	err := s.db.WithContext(ctx).
+		Model(&models.UserPoint{}).
+		Where(
+			"chain_id=? AND contract_address=? AND last_calc_time < ?",
+			chainID, contract, now.UTC(),
+		).
+		Order("account").
+		Pluck("account", &accounts).Error
+	return accounts, err
+}
@@ -0,0 +1,98 @@
+package calculator
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// sqlIdlePoints 按 accrueIdle 中 insertLog 的表达式逐步计算：
+// TIMESTAMPDIFF(SECOND) 向下取整，balance / 10^decimals（DECIMAL(65,30)），
+// 乘 rate 与秒数，除以 3600（保留 30 位），最后 ROUND(..., 16)
+func sqlIdlePoints(balanceWei *big.Int, decimals int32, rate decimal.Decimal, from, to time.Time) decimal.Decimal {
+	seconds := decimal.NewFromInt(int64(to.Sub(from) / time.Second))
+	bal := decimal.NewFromBigInt(balanceWei, -decimals)
+	return bal.Mul(rate).Mul(seconds).DivRound(decimal.NewFromInt(3600), batchMaxDecimals).Round(16)
+}
+
+// 批量路径与分段计算（calcOneAccount 使用的 buildSegments）对同一 idle 区间给出相同积分
+func TestIdleAccrualMatchesSegments(t *testing.T) {
+	wei := func(s string) *big.Int {
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			t.Fatalf("bad wei %q", s)
+		}
+		return v
+	}
+	rateOf := func(num, den int64) ratePoint {
+		return ratePoint{
+			At:              testT0.Add(-time.Hour),
+			RateNumerator:   num,
+			RateDenominator: den,
+			Rate:            decimal.NewFromInt(num).Div(decimal.NewFromInt(den)),
+		}
+	}
+
+	cases := []struct {
+		name     string
+		balance  string
+		decimals int32
+		rate     ratePoint
+		span     time.Duration
+		want     string
+	}{
+		{"18 decimals one hour", "1500000000000000000", 18, rateOf(5, 100), time.Hour, "0.075"},
+		{"fractional seconds are dropped", "3600", 0, rateOf(1, 1), 10*time.Second + 999*time.Millisecond, "10"},
+		{"rounds to 16 places", "1", 0, rateOf(1, 3), time.Second, "0.0000925925925926"},
+		{"6 decimals", "123456789", 6, rateOf(7, 100), 86399 * time.Second, "207.405004971325"},
+		{"widest batch balance", "99999999999999999999999999999999999", 30, rateOf(1, 1), time.Hour, "100000"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bal := wei(tc.balance)
+			t1 := testT0.Add(tc.span)
+
+			pd, err := buildSegments(
+				bal, nil, []ratePoint{tc.rate}, []boostPoint{{At: testT0, Multiplier: decimal.NewFromInt(1)}},
+				tc.decimals, testT0, t1,
+			)
+			if err != nil {
+				t.Fatalf("build segments: %v", err)
+			}
+
+			got := sqlIdlePoints(bal, tc.decimals, tc.rate.Rate, testT0, t1)
+			if !got.Equal(pd.Total) {
+				t.Fatalf("batch points %s, segment points %s", got, pd.Total)
+			}
+			if !got.Equal(decimal.RequireFromString(tc.want)) {
+				t.Fatalf("points = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// 位数上限内的最大余额：展示值不超过 decimal(38,18)，原始值可以 CAST 到 DECIMAL(65,30)
+func TestIdleBalanceMaxDigits(t *testing.T) {
+	limit := decimal.New(1, batchLogMaxDigits)
+
+	for _, tc := range []struct {
+		decimals int32
+		want     int
+	}{{0, 20}, {6, 26}, {18, 35}, {30, 35}} {
+		digits := idleBalanceMaxDigits(tc.decimals)
+		if digits != tc.want {
+			t.Fatalf("decimals %d: max digits %d, want %d", tc.decimals, digits, tc.want)
+		}
+
+		widest := new(big.Int).Sub(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil), big.NewInt(1))
+		if !decimal.NewFromBigInt(widest, -tc.decimals).LessThan(limit) {
+			t.Fatalf("decimals %d: %d-digit balance does not fit decimal(38,18)", tc.decimals, digits)
+		}
+		if digits > batchCastMaxDigits {
+			t.Fatalf("decimals %d: %d digits overflow DECIMAL(65,30)", tc.decimals, digits)
+		}
+	}
+}
@@ -5,59 +5,56 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/config"
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/lease"
 )
 
 type Service struct {
 	db  *gorm.DB
 	cfg *config.Config
+
+	// 多副本协调：按合约持有租约
+	leases *lease.Keeper
+
+	// 积分写事务的全局并发上限（calculator.db_concurrency）
+	dbSem chan struct{}
+
+	// 每个合约上次成功计算到的 safe block 时间，未推进时跳过
+	lastMu   sync.Mutex
+	lastSafe map[string]time.Time
 }
 
 func New(db *gorm.DB, cfg *config.Config) *Service {
-	return &Service{db: db, cfg: cfg}
-}
+	return &Service{
+		db:     db,
+		cfg:    cfg,
+		leases: lease.NewKeeper(db, cfg.Coordination),
+		dbSem:  make(chan struct{}, dbConcurrency(cfg.Calculator)),
 
-// StartHourly：每小时跑一次
-func (s *Service) StartHourly(ctx context.Context) {
-	// 对齐到整点（时间可修改）
-	next := time.Now().UTC().Truncate(time.Hour).Add(time.Hour)
-	timer := time.NewTimer(time.Until(next))
-	defer timer.Stop()
-
-	select {
-	case <-ctx.Done():
-		return
-	case <-timer.C:
+		lastSafe: make(map[string]time.Time),
 	}
+}
 
-	ticker := time.NewTicker(time.Hour)
-	defer ticker.Stop()
-
-	for {
-		now := time.Now().UTC().Truncate(time.Hour)
-		if err := s.RunOnce(ctx, now); err != nil {
-			log.Printf("[calculator] run failed: %v", err)
-		} else {
-			log.Printf("[calculator] run ok at %s", now.Format(time.RFC3339))
-		}
-
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-		}
+// dbConcurrency 未配置时按合约并发 × 分片并发
+func dbConcurrency(cc config.CalculatorConfig) int {
+	if cc.DBConcurrency > 0 {
+		return cc.DBConcurrency
 	}
+	return max(cc.ContractWorkers, 1) * max(cc.ShardWorkers, 1)
 }
 
 // RunOnce：对所有 DB 中的 Active 合约，计算积分并落库
+// 合约按 calculator.contract_workers 并发，单个合约内按账户分片并发
 func (s *Service) RunOnce(ctx context.Context, now time.Time) error {
 
 	// 从数据库获取任务
@@ -66,85 +63,177 @@ func (s *Service) RunOnce(ctx context.Context, now time.Time) error {
 		return fmt.Errorf("load active contracts failed: %w", err)
 	}
 
+	workers := s.cfg.Calculator.ContractWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		g    errgroup.Group
+		mu   sync.Mutex
+		errs []error
+	)
+	g.SetLimit(workers)
+
 	for _, c := range contracts {
-		// 以 safe block 的 block_time 作为积分上界
-		safeT, err := s.safeBlockTime(ctx, c.ChainID, c.Address)
-		if err != nil {
-			log.Printf("[ERROR] get safeBlockTime failed chain=%d contract=%s: %v", c.ChainID, c.Address, err)
+		// decimals 未与链上核对前不计算，避免按错误精度累计积分
+		if !c.IsNFT() && !c.DecimalsVerified {
+			log.Printf("[calculator] skip chain=%d contract=%s: decimals not verified yet", c.ChainID, c.Address)
 			continue
 		}
+		c := c
 
-		// 传入 SysContract 对象，以便后续获取分表名
-		if err := s.runContract(ctx, c, safeT); err != nil {
-			log.Printf("[ERROR] runContract failed chain=%d contract=%s: %v", c.ChainID, c.Address, err)
+		g.Go(func() error {
+			if err := s.runContractWorker(ctx, c); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+	return errors.Join(errs...)
+}
+
+// runContractWorker 获取合约租约后计算单个合约并记录 calc_run
+// 由其他副本持有租约、或 safe 时间未推进时跳过（返回 nil）
+func (s *Service) runContractWorker(ctx context.Context, c models.SysContract) error {
+	// 获取合约租约，由其他副本持有时跳过
+	l, ok, err := s.leases.Acquire(ctx, lease.CalculatorName(c.ChainID, c.Address))
+	if err != nil {
+		return fmt.Errorf("acquire lease failed chain=%d contract=%s: %w", c.ChainID, c.Address, err)
+	}
+	if !ok {
+		return nil
+	}
+	leaseCtx := lease.WithLease(ctx, l)
+
+	// 回溯重算任务优先（规则变更影响已发放的积分）；失败已记录在 recompute_job，不阻塞常规计算
+	if _, err := s.runRecomputeJobs(leaseCtx, c, false); err != nil {
+		if errors.Is(err, repository.ErrLeaseLost) {
+			return fmt.Errorf("recompute chain=%d contract=%s: %w", c.ChainID, c.Address, err)
 		}
+		log.Printf("[ERROR] recompute chain=%d contract=%s: %v", c.ChainID, c.Address, err)
+	}
+
+	// 以 safe block 的 block_time 作为积分上界
+	safeT, err := s.safeBlockTime(ctx, c.ChainID, c.Address)
+	if err != nil {
+		return fmt.Errorf("get safeBlockTime failed chain=%d contract=%s: %w", c.ChainID, c.Address, err)
 	}
+
+	// cursor 未推进：上次已算到同一 safe 时间
+	key := contractKey(c.ChainID, c.Address)
+	s.lastMu.Lock()
+	last, seen := s.lastSafe[key]
+	s.lastMu.Unlock()
+	if seen && last.Equal(safeT) {
+		return nil
+	}
+
+	run, err := s.startRun(ctx, c, safeT)
+	if err != nil {
+		return fmt.Errorf("create calc_run failed chain=%d contract=%s: %w", c.ChainID, c.Address, err)
+	}
+
+	// 传入 SysContract 对象，以便后续获取分表名
+	stats := newRunStats()
+	runErr := s.runContract(leaseCtx, c, safeT, stats)
+	if runErr != nil {
+		runErr = fmt.Errorf("runContract failed chain=%d contract=%s: %w", c.ChainID, c.Address, runErr)
+	}
+	if err := s.finishRun(ctx, run, stats, runErr); err != nil {
+		return err
+	}
+
+	// 完全成功才记录；partial 的下一轮继续重试失败账户
+	s.lastMu.Lock()
+	s.lastSafe[key] = safeT
+	s.lastMu.Unlock()
+	log.Printf("[calculator] run ok chain=%d contract=%s safe_time=%s", c.ChainID, c.Address, safeT.Format(time.RFC3339))
 	return nil
 }
 
-func (s *Service) runContract(ctx context.Context, contract models.SysContract, now time.Time) error {
+func (s *Service) runContract(
+	ctx context.Context,
+	contract models.SysContract,
+	now time.Time,
+	stats *runStats,
+) error {
 	chainID := contract.ChainID
 	addr := contract.Address
+	decimals := contract.BalanceDecimals()
 
-	// 1) 找到本合约下“所有已出现过的用户”（从 user_balance）
-	var accounts []string
-	if err := s.db.WithContext(ctx).
-		Model(&models.UserBalance{}).
-		Where("chain_id=? AND contract_address=?", chainID, addr).
-		Pluck("account", &accounts).Error; err != nil {
-		return fmt.Errorf("load accounts failed chain=%d contract=%s: %w", chainID, addr, err)
-	}
+	// 获取动态表名 (例如 user_point_log_1)
+	logTableName := contract.GetLogTableName()
 
-	if len(accounts) == 0 {
-		return nil
+	// 0) 上一轮崩溃遗留的分片按原 run_time 续跑
+	prev, ok, err := s.unfinishedRun(ctx, chainID, addr, now)
+	if err != nil {
+		return fmt.Errorf("load unfinished shards failed chain=%d contract=%s: %w", chainID, addr, err)
 	}
-
-	// 2) 确保每个 account 都有 user_point（没有则初始化）
-	for _, acct := range accounts {
-		initT, err := s.firstSeenTime(ctx, chainID, addr, acct, now)
-		if err != nil {
-			return fmt.Errorf("load first seen time failed acct=%s: %w", acct, err)
+	if ok {
+		log.Printf("[calculator] resume chain=%d contract=%s run_time=%s", chainID, addr, prev.Format(time.RFC3339))
+		if err := s.runShards(ctx, contract, prev, logTableName, stats); err != nil {
+			return err
 		}
+	}
 
-		up := models.UserPoint{
-			ChainID:         chainID,
-			ContractAddress: addr,
-			Account:         acct,
-			TotalPoints:     "0",
-			LastCalcTime:    initT,
-			UpdatedAt:       time.Now().UTC(),
-		}
+	// 1) 确保每个出现过的账户（user_balance）都有 user_point（没有则初始化）
+	if err := s.acquireDB(ctx); err != nil {
+		return err
+	}
+	_, err = s.ensureUserPoints(ctx, chainID, addr, now)
+	s.releaseDB()
+	if err != nil {
+		return fmt.Errorf("ensure user_point failed chain=%d contract=%s: %w", chainID, addr, err)
+	}
 
-		if err := s.db.WithContext(ctx).
-			Clauses(clause.OnConflict{DoNothing: true}).
-			Create(&up).Error; err != nil {
-			return fmt.Errorf("ensure user_point failed acct=%s: %w", acct, err)
+	// 2) idle 账户整批结算
+	if err := s.acquireDB(ctx); err != nil {
+		return err
+	}
+	idle, idlePoints, err := s.accrueIdle(ctx, contract, decimals, now, logTableName)
+	s.releaseDB()
+	stats.addIdle(idle, idlePoints)
+	if err != nil {
+		if errors.Is(err, repository.ErrLeaseLost) {
+			return err
 		}
+		// 批量失败不影响正确性，全部退回分段计算
+		log.Printf("[ERROR] accrueIdle failed: chain=%d contract=%s err=%v", chainID, addr, err)
 	}
 
-	// 获取动态表名 (例如 user_point_log_1)
-	logTableName := contract.GetLogTableName()
+	log.Printf("[calculator] chain=%d contract=%s idle=%d", chainID, addr, idle)
 
-	// 3) 对每个账户补算
-	for _, acct := range accounts {
-		if err := s.calcOneAccount(ctx, chainID, addr, acct, now, logTableName); err != nil {
-			log.Printf("[ERROR] calcOneAccount failed: chain=%d contract=%s account=%s err=%v", chainID, addr, acct, err)
-			continue
-		}
+	// 3) 其余账户按分片并发分段补算
+	if err := s.runShards(ctx, contract, now, logTableName, stats); err != nil {
+		return err
 	}
 
-	return nil
+	// 4) 失败账户重试
+	return s.retryFailed(ctx, contract, now, logTableName, stats)
 }
 
 func (s *Service) calcOneAccount(
 	ctx context.Context,
 	chainID int64,
 	contract, account string,
+	decimals int32,
 	now time.Time,
 	logTableName string, // 【新增参数】动态表名
-) error {
+) (decimal.Decimal, error) {
+
+	issued := decimal.Zero
 
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+
+		// 0) fencing：租约已易主则拒绝写入
+		if err := s.leases.CheckFence(ctx, tx); err != nil {
+			return err
+		}
 
 		// 1) 锁住 user_point
 		var up models.UserPoint
@@ -172,6 +261,7 @@ func (s *Service) calcOneAccount(
 			chainID,
 			contract,
 			account,
+			decimals,
 			t0,
 			t1,
 		)
@@ -197,30 +287,8 @@ func (s *Service) calcOneAccount(
 		}
 
 		// 4) 写入积分派生事实（user_point_log）
-		for _, seg := range pd.Segments {
-			if seg.Points.IsZero() {
-				continue
-			}
-
-			pl := models.UserPointLog{
-				ChainID:         chainID,
-				ContractAddress: contract,
-				Account:         account,
-				FromTime:        seg.FromTime,
-				ToTime:          seg.ToTime,
-				Balance:         seg.Balance.String(),
-				Points:          seg.Points.String(),
-				RateNumerator:   seg.RateNumerator,
-				RateDenominator: seg.RateDenominator,
-				CreatedAt:       nowUTC,
-			}
-
-			// 关键！使用 Table(logTableName) 写入分表
-			if err := tx.Table(logTableName).
-				Clauses(clause.OnConflict{DoNothing: true}).
-				Create(&pl).Error; err != nil {
-				return err
-			}
+		if err := insertPointSegments(tx, logTableName, chainID, contract, account, pd.Segments, nowUTC); err != nil {
+			return err
 		}
 
 		// 5) 更新积分快照（user_point 总表不变）
@@ -230,49 +298,67 @@ func (s *Service) calcOneAccount(
 		}
 		total = total.Add(deltaPoints)
 
-		return tx.Model(&models.UserPoint{}).
+		if err := tx.Model(&models.UserPoint{}).
 			Where("id=?", up.ID).
 			Updates(map[string]any{
 				"total_points":   total.String(),
 				"last_calc_time": t1,
 				"updated_at":     nowUTC,
-			}).Error
-	})
-}
+			}).Error; err != nil {
+			return err
+		}
 
-var ErrNotFound = gorm.ErrRecordNotFound
+		issued = deltaPoints
+		return nil
+	})
 
-func isNotFound(err error) bool {
-	return errors.Is(err, ErrNotFound)
+	return issued, err
 }
 
-func (s *Service) firstSeenTime(
-	ctx context.Context,
+// insertPointSegments 把非零积分的分段写入分表 user_point_log_{id}
+func insertPointSegments(
+	tx *gorm.DB,
+	logTableName string,
 	chainID int64,
-	contract string,
-	account string,
-	fallback time.Time,
-) (time.Time, error) {
+	contract, account string,
+	segments []PointSegment,
+	now time.Time,
+) error {
 
-	type row struct {
-		T time.Time
-	}
+	for _, seg := range segments {
+		if seg.Points.IsZero() {
+			continue
+		}
 
-	var r row
-	if err := s.db.WithContext(ctx).
-		Model(&models.BalanceLog{}).
-		Select("MIN(block_time) AS t").
-		Where("chain_id=? AND contract_address=? AND account=?",
-			chainID, contract, account,
-		).
-		Scan(&r).Error; err != nil {
-		return time.Time{}, err
-	}
+		pl := models.UserPointLog{
+			ChainID:         chainID,
+			ContractAddress: contract,
+			Account:         account,
+			FromTime:        seg.FromTime,
+			ToTime:          seg.ToTime,
+			Balance:         seg.Balance.String(),
+			Points:          seg.Points.String(),
+			RateNumerator:   seg.RateNumerator,
+			RateDenominator: seg.RateDenominator,
+			FormulaID:       seg.FormulaID,
+			BoostMultiplier: seg.Multiplier.String(),
+			CreatedAt:       now,
+		}
 
-	if r.T.IsZero() {
-		return fallback.UTC(), nil
+		// 关键！使用 Table(logTableName) 写入分表
+		if err := tx.Table(logTableName).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&pl).Error; err != nil {
+			return err
+		}
 	}
-	return r.T.UTC(), nil
+	return nil
+}
+
+var ErrNotFound = gorm.ErrRecordNotFound
+
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
 }
 
 func (s *Service) safeBlockTime(
@@ -10,6 +10,7 @@ import (
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/formula"
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
 )
 
@@ -18,6 +19,15 @@ type ratePoint struct {
 	RateNumerator   int64
 	RateDenominator int64
 	Rate            decimal.Decimal // 仅用于计算 = num/den
+
+	FormulaID uint64           // 0 = 线性
+	Formula   *formula.Formula // FormulaID != 0 时已解析的公式
+}
+
+// boostPoint 从 At 起生效的账户倍率（所有重叠 boost 的乘积）
+type boostPoint struct {
+	At         time.Time
+	Multiplier decimal.Decimal
 }
 
 type balPoint struct {
@@ -33,6 +43,8 @@ type PointSegment struct {
 	Balance         decimal.Decimal
 	RateNumerator   int64
 	RateDenominator int64
+	FormulaID       uint64
+	Multiplier      decimal.Decimal // 账户 boost 倍率，无 boost 为 1
 	Points          decimal.Decimal
 }
 
@@ -41,19 +53,46 @@ type PointsDelta struct {
 	Segments []PointSegment
 }
 
+// BalanceChange 额外的余额变化点（如未确认的 pending 区块），排在已落库的变化之后
+type BalanceChange struct {
+	At           time.Time
+	BlockNumber  int64
+	LogIndex     int
+	BalanceAfter *big.Int
+}
+
 // ComputePointsDelta：计算 [t0, t1) 内应增加的积分（不落库）
 //
 // 核心：把时间线切成小段：
 // - 余额变化点（balance_log.block_time）
 // - rate 变化点（point_rate.effective_time）
 // 对每段做： balance * rate * (durationSeconds / 3600)
+// - 账户 boost 变化点（point_boost.start_time / end_time）
+// rate 带公式时 balance 先按公式换算为有效余额（见 internal/formula），结果再乘 boost 倍率
+//
+// decimals 为余额换算精度（ERC20 通常为 18，NFT 按个数计为 0）
 func ComputePointsDelta(
 	ctx context.Context,
 	db *gorm.DB,
 	chainID int64,
 	contract, account string,
+	decimals int32,
 	t0, t1 time.Time,
 ) (PointsDelta, error) {
+	return ComputePointsDeltaWith(ctx, db, chainID, contract, account, decimals, t0, t1, nil)
+}
+
+// ComputePointsDeltaWith 同 ComputePointsDelta，并在已落库的余额时间线之后叠加 extra
+// 用于 API 的 latest 视图估算未确认区块带来的积分，结果不落库
+func ComputePointsDeltaWith(
+	ctx context.Context,
+	db *gorm.DB,
+	chainID int64,
+	contract, account string,
+	decimals int32,
+	t0, t1 time.Time,
+	extra []BalanceChange,
+) (PointsDelta, error) {
 
 	// 1) 取 t0 时刻起始余额：最后一条 block_time < t0 的 balance_after
 	startBal, err := loadBalanceAt(ctx, db, chainID, contract, account, t0)
@@ -67,6 +106,24 @@ func ComputePointsDelta(
 		return PointsDelta{}, err
 	}
 
+	// 额外变化点：早于 t0 的直接作为起始余额，[t0, t1) 内的追加到时间线
+	for _, e := range extra {
+		at := e.At.UTC()
+		if at.Before(t0) {
+			startBal = e.BalanceAfter
+			continue
+		}
+		if !at.Before(t1) {
+			break
+		}
+		bals = append(bals, balPoint{
+			At:           at,
+			BlockNumber:  e.BlockNumber,
+			LogIndex:     e.LogIndex,
+			BalanceAfter: e.BalanceAfter,
+		})
+	}
+
 	// 3) 取 rate 时间线：需要 t0 生效的 rate + (t0, t1) 内的变更
 	rates, err := loadRateTimeline(ctx, db, chainID, contract, t0, t1)
 	if err != nil {
@@ -76,7 +133,27 @@ func ComputePointsDelta(
 		return PointsDelta{}, fmt.Errorf("no point_rate found for chain=%d contract=%s", chainID, contract)
 	}
 
-	// 4) 合并所有切割点（t0, t1 + rate/balance change times）
+	// 3.1) 取账户 boost 时间线：t0 生效的倍率 + (t0, t1) 内的变化
+	boosts, err := loadBoostTimeline(ctx, db, chainID, contract, account, t0, t1)
+	if err != nil {
+		return PointsDelta{}, err
+	}
+
+	return buildSegments(startBal, bals, rates, boosts, decimals, t0, t1)
+}
+
+// buildSegments 按余额 / rate / boost 变化点切分 [t0, t1) 并逐段计算积分（纯计算，不访问 DB）
+// rates 至少一项且 rates[0] 在 t0 生效；boosts 第一项为 t0 时刻的倍率
+func buildSegments(
+	startBal *big.Int,
+	bals []balPoint,
+	rates []ratePoint,
+	boosts []boostPoint,
+	decimals int32,
+	t0, t1 time.Time,
+) (PointsDelta, error) {
+
+	// 4) 合并所有切割点（t0, t1 + rate/balance/boost change times）
 	// 我们用“指针推进”而不是构建全集表，更稳更快。
 	curTime := t0.UTC()
 	curBal := startBal
@@ -90,6 +167,10 @@ func ComputePointsDelta(
 	curRatePoint := rates[ri]
 	curRate := curRatePoint.Rate
 
+	// boost 指针：boosts[0].At == t0
+	oi := 0
+	curBoost := boosts[0].Multiplier
+
 	total := decimal.Zero
 
 	segments := make([]PointSegment, 0)
@@ -123,19 +204,37 @@ func ComputePointsDelta(
 			}
 		}
 
+		// 下一个 boost 变化时间
+		if oi+1 < len(boosts) {
+			ot := boosts[oi+1].At
+			if ot.After(curTime) && ot.Before(nextTime) {
+				nextTime = ot
+			} else if ot.Equal(curTime) {
+				oi++
+				curBoost = boosts[oi].Multiplier
+				continue
+			}
+		}
+
 		// 计算 [curTime, nextTime) 的积分
 		if nextTime.After(curTime) {
 			seconds := int64(nextTime.Sub(curTime).Seconds())
 
-			if seconds > 0 && curBal.Sign() > 0 && curRate.GreaterThan(decimal.Zero) {
-				segPoints := pointsForSegment(curBal, curRate, seconds)
+			if seconds > 0 && curBal.Sign() > 0 && curRate.GreaterThan(decimal.Zero) && curBoost.IsPositive() {
+				segPoints, err := pointsForSegment(curBal, decimals, curRate, seconds, curRatePoint.Formula)
+				if err != nil {
+					return PointsDelta{}, fmt.Errorf("evaluate formula %d failed: %w", curRatePoint.FormulaID, err)
+				}
+				segPoints = segPoints.Mul(curBoost)
 				// 记录积分变化的 log
 				segments = append(segments, PointSegment{
 					FromTime:        curTime,
 					ToTime:          nextTime,
-					Balance:         decimal.NewFromBigInt(curBal, -18),
+					Balance:         decimal.NewFromBigInt(curBal, -decimals),
 					RateNumerator:   curRatePoint.RateNumerator,
 					RateDenominator: curRatePoint.RateDenominator,
+					FormulaID:       curRatePoint.FormulaID,
+					Multiplier:      curBoost,
 					Points:          segPoints,
 				})
 
@@ -152,14 +251,26 @@ func ComputePointsDelta(
 		Total:    total,
 		Segments: segments,
 	}, nil
-
 }
 
-func pointsForSegment(balanceWei *big.Int, rate decimal.Decimal, seconds int64) decimal.Decimal {
+func pointsForSegment(
+	balanceWei *big.Int,
+	decimals int32,
+	rate decimal.Decimal,
+	seconds int64,
+	f *formula.Formula,
+) (decimal.Decimal, error) {
 	// balance * rate * seconds / 3600
-	balDec := decimal.NewFromBigInt(balanceWei, -18)
+	balDec := decimal.NewFromBigInt(balanceWei, -decimals)
+	if f != nil {
+		eff, err := f.EffectiveBalance(balDec)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		balDec = eff
+	}
 	sec := decimal.NewFromInt(seconds)
-	return balDec.Mul(rate).Mul(sec).Div(decimal.NewFromInt(3600))
+	return balDec.Mul(rate).Mul(sec).Div(decimal.NewFromInt(3600)), nil
 }
 
 /* ---------------- DB loads ---------------- */
@@ -269,12 +380,13 @@ func loadRateTimeline(
 		EffectiveTime   time.Time
 		RateNumerator   int64
 		RateDenominator int64
+		FormulaID       uint64
 	}
 
 	var base row
 	if err := db.WithContext(ctx).
 		Model(&models.PointRate{}).
-		Select("effective_time, rate_numerator, rate_denominator").
+		Select("effective_time, rate_numerator, rate_denominator, formula_id").
 		Where("chain_id=? AND contract_address=? AND effective_time <= ?",
 			chainID, contract, t0,
 		).
@@ -295,13 +407,14 @@ func loadRateTimeline(
 		RateNumerator:   base.RateNumerator,
 		RateDenominator: base.RateDenominator,
 		Rate:            decimal.NewFromInt(base.RateNumerator).Div(decimal.NewFromInt(base.RateDenominator)),
+		FormulaID:       base.FormulaID,
 	}}
 
 	// 取 (t0, t1) 内的变更
 	var changes []row
 	if err := db.WithContext(ctx).
 		Model(&models.PointRate{}).
-		Select("effective_time, rate_numerator, rate_denominator").
+		Select("effective_time, rate_numerator, rate_denominator, formula_id").
 		Where("chain_id=? AND contract_address=? AND effective_time > ? AND effective_time < ?",
 			chainID, contract, t0, t1,
 		).
@@ -316,9 +429,14 @@ func loadRateTimeline(
 			RateNumerator:   c.RateNumerator,
 			RateDenominator: c.RateDenominator,
 			Rate:            decimal.NewFromInt(c.RateNumerator).Div(decimal.NewFromInt(c.RateDenominator)),
+			FormulaID:       c.FormulaID,
 		})
 	}
 
+	if err := attachFormulas(ctx, db, out); err != nil {
+		return nil, err
+	}
+
 	// 确保有序
 	sort.SliceStable(out, func(i, j int) bool {
 		return out[i].At.Before(out[j].At)
@@ -327,6 +445,119 @@ func loadRateTimeline(
 	return out, nil
 }
 
+// attachFormulas 加载 rates 引用的公式版本
+func attachFormulas(ctx context.Context, db *gorm.DB, rates []ratePoint) error {
+	ids := make([]uint64, 0)
+	for _, r := range rates {
+		if r.FormulaID != 0 {
+			ids = append(ids, r.FormulaID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var rows []models.RateFormula
+	if err := db.WithContext(ctx).
+		Where("id IN ?", ids).
+		Find(&rows).Error; err != nil {
+		return err
+	}
+
+	parsed := make(map[uint64]*formula.Formula, len(rows))
+	for _, r := range rows {
+		f, err := formula.Parse(r.Definition)
+		if err != nil {
+			return fmt.Errorf("rate_formula %d: %w", r.ID, err)
+		}
+		parsed[r.ID] = f
+	}
+
+	for i := range rates {
+		if rates[i].FormulaID == 0 {
+			continue
+		}
+		f, ok := parsed[rates[i].FormulaID]
+		if !ok {
+			return fmt.Errorf("rate_formula %d not found", rates[i].FormulaID)
+		}
+		rates[i].Formula = f
+	}
+	return nil
+}
+
+// loadBoostTimeline 账户在 [t0, t1) 内的 boost 倍率变化点，第一项为 t0 时刻的倍率
+func loadBoostTimeline(
+	ctx context.Context,
+	db *gorm.DB,
+	chainID int64,
+	contract, account string,
+	t0, t1 time.Time,
+) ([]boostPoint, error) {
+
+	var rows []models.PointBoost
+	if err := db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND account=? AND start_time < ? AND (end_time IS NULL OR end_time > ?)",
+			chainID, contract, account, t1, t0,
+		).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return boostTimeline(rows, t0, t1)
+}
+
+// boostTimeline 由 boost 记录构造 [t0, t1) 内的倍率变化点（纯计算）
+// 切割点为 t0 与落在 (t0, t1) 内的 start_time / end_time；重叠的 boost 倍率相乘
+func boostTimeline(rows []models.PointBoost, t0, t1 time.Time) ([]boostPoint, error) {
+	t0, t1 = t0.UTC(), t1.UTC()
+
+	type boost struct {
+		start, end time.Time // end 为零表示长期有效
+		mult       decimal.Decimal
+	}
+
+	list := make([]boost, 0, len(rows))
+	cuts := []time.Time{t0}
+	for _, r := range rows {
+		m, err := decimal.NewFromString(r.Multiplier)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point_boost %d multiplier: %w", r.ID, err)
+		}
+		b := boost{start: r.StartTime.UTC(), mult: m}
+		if r.EndTime != nil {
+			b.end = r.EndTime.UTC()
+		}
+		list = append(list, b)
+
+		for _, t := range []time.Time{b.start, b.end} {
+			if t.After(t0) && t.Before(t1) {
+				cuts = append(cuts, t)
+			}
+		}
+	}
+
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].Before(cuts[j]) })
+
+	out := make([]boostPoint, 0, len(cuts))
+	for _, at := range cuts {
+		if n := len(out); n > 0 && out[n-1].At.Equal(at) {
+			continue
+		}
+
+		mult := decimal.NewFromInt(1)
+		for _, b := range list {
+			if !b.start.After(at) && (b.end.IsZero() || b.end.After(at)) {
+				mult = mult.Mul(b.mult)
+			}
+		}
+		out = append(out, boostPoint{At: at, Multiplier: mult})
+	}
+
+	return out, nil
+}
+
 /* ---------------- rate helpers ---------------- */
 
 func rateIndexAtOrBefore(rates []ratePoint, t time.Time) int {
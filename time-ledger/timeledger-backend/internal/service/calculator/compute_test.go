@@ -0,0 +1,126 @@
+package calculator
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+var testT0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func hoursAfterT0(h int) time.Time { return testT0.Add(time.Duration(h) * time.Hour) }
+
+func testBoost(mult string, start int, end *int) models.PointBoost {
+	b := models.PointBoost{Multiplier: mult, StartTime: hoursAfterT0(start)}
+	if end != nil {
+		e := hoursAfterT0(*end)
+		b.EndTime = &e
+	}
+	return b
+}
+
+func TestBoostTimeline(t *testing.T) {
+	at := func(h int) *int { return &h }
+	t1 := hoursAfterT0(4)
+
+	type point struct {
+		hour int
+		mult string
+	}
+
+	cases := []struct {
+		name   string
+		boosts []models.PointBoost
+		want   []point
+	}{
+		{"none", nil, []point{{0, "1"}}},
+		{
+			"overlapping multiply",
+			[]models.PointBoost{testBoost("2", 1, at(3)), testBoost("1.5", 2, nil)},
+			[]point{{0, "1"}, {1, "2"}, {2, "3"}, {3, "1.5"}},
+		},
+		{"starts at t0", []models.PointBoost{testBoost("2", 0, at(2))}, []point{{0, "2"}, {2, "1"}}},
+		{"starts before t0", []models.PointBoost{testBoost("2", -1, nil)}, []point{{0, "2"}}},
+		{"ends at t0", []models.PointBoost{testBoost("2", -2, at(0))}, []point{{0, "1"}}},
+		{"ends at t1", []models.PointBoost{testBoost("2", 1, at(4))}, []point{{0, "1"}, {1, "2"}}},
+		{"starts at t1", []models.PointBoost{testBoost("2", 4, nil)}, []point{{0, "1"}}},
+		{
+			"adjacent boosts share a cut point",
+			[]models.PointBoost{testBoost("2", 1, at(2)), testBoost("3", 2, nil)},
+			[]point{{0, "1"}, {1, "2"}, {2, "3"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := boostTimeline(tc.boosts, testT0, t1)
+			if err != nil {
+				t.Fatalf("boost timeline: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d points %v, want %d", len(got), got, len(tc.want))
+			}
+			for i, w := range tc.want {
+				if !got[i].At.Equal(hoursAfterT0(w.hour)) || !got[i].Multiplier.Equal(decimal.RequireFromString(w.mult)) {
+					t.Fatalf("point %d = (%s, %s), want (+%dh, %s)",
+						i, got[i].At.Format(time.RFC3339), got[i].Multiplier, w.hour, w.mult)
+				}
+			}
+		})
+	}
+}
+
+// boost 的起止与 rate / 余额变化落在同一时刻：每段使用该时刻之后的 rate、余额与倍率
+func TestBuildSegmentsBoostOnCutPoints(t *testing.T) {
+	t1 := hoursAfterT0(3)
+	end := 2
+
+	rates := []ratePoint{
+		{At: hoursAfterT0(-1), RateNumerator: 1, RateDenominator: 1, Rate: decimal.NewFromInt(1)},
+		{At: hoursAfterT0(1), RateNumerator: 2, RateDenominator: 1, Rate: decimal.NewFromInt(2)},
+	}
+	bals := []balPoint{{At: hoursAfterT0(2), BalanceAfter: big.NewInt(20)}}
+
+	boosts, err := boostTimeline([]models.PointBoost{
+		testBoost("2", 1, &end), // 与 rate 变化同时开始，与余额变化同时结束
+		testBoost("3", 2, nil),  // 与余额变化同时开始
+	}, testT0, t1)
+	if err != nil {
+		t.Fatalf("boost timeline: %v", err)
+	}
+
+	pd, err := buildSegments(big.NewInt(10), bals, rates, boosts, 0, testT0, t1)
+	if err != nil {
+		t.Fatalf("build segments: %v", err)
+	}
+
+	want := []struct {
+		balance, mult, points string
+	}{
+		{"10", "1", "10"},  // [0h, 1h) 10 * 1 * 1
+		{"10", "2", "40"},  // [1h, 2h) 10 * 2 * 2
+		{"20", "3", "120"}, // [2h, 3h) 20 * 2 * 3
+	}
+	if len(pd.Segments) != len(want) {
+		t.Fatalf("got %d segments, want %d", len(pd.Segments), len(want))
+	}
+	for i, w := range want {
+		seg := pd.Segments[i]
+		if !seg.FromTime.Equal(hoursAfterT0(i)) || !seg.ToTime.Equal(hoursAfterT0(i+1)) {
+			t.Fatalf("segment %d spans [%s, %s)", i, seg.FromTime, seg.ToTime)
+		}
+		if !seg.Balance.Equal(decimal.RequireFromString(w.balance)) ||
+			!seg.Multiplier.Equal(decimal.RequireFromString(w.mult)) ||
+			!seg.Points.Equal(decimal.RequireFromString(w.points)) {
+			t.Fatalf("segment %d = balance %s mult %s points %s, want %s %s %s",
+				i, seg.Balance, seg.Multiplier, seg.Points, w.balance, w.mult, w.points)
+		}
+	}
+	if !pd.Total.Equal(decimal.NewFromInt(170)) {
+		t.Fatalf("total = %s, want 170", pd.Total)
+	}
+}
@@ -0,0 +1,375 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/lease"
+)
+
+/*
+Recompute
+---------
+- point_rate 插入 / 修正的 effective_time 早于已结算时间时，repository 创建 recompute_job
+- calculator 持有合约租约时先执行该合约的重算任务，再做常规计算
+- 任务带 account 时只重算该账户（账户 boost 变更），否则重算合约内全部受影响账户
+- 每个账户一个事务：删除 to_time > from_time 的积分日志，从被删除的第一段起点重新分段计算到 last_calc_time，
+  按差额调整 total_points 并写 point_correction 审计记录
+- 重算只依赖当前 DB 中的规则与余额，可重入：中途失败重跑时已重算的账户差额为 0
+- 失败的任务按指数退避（recomputeRetryBase 起，最长 recomputeRetryMax）自动重试，
+  超过 recomputeMaxAttempts 次后保持 failed，由 ledgerctl recompute 手工重跑
+*/
+
+const (
+	recomputeMaxAttempts = 5
+	recomputeRetryBase   = time.Minute
+	recomputeRetryMax    = time.Hour
+)
+
+// recomputeBackoff 第 attempts 次失败后的重试间隔
+func recomputeBackoff(attempts int) time.Duration {
+	d := recomputeRetryBase
+	for i := 1; i < attempts && d < recomputeRetryMax; i++ {
+		d *= 2
+	}
+	if d > recomputeRetryMax {
+		d = recomputeRetryMax
+	}
+	return d
+}
+
+// ErrCalculatorBusy 合约正在被其他实例计算，无法手动重算
+var ErrCalculatorBusy = errors.New("contract is being calculated by another worker")
+
+// Recompute 手动执行合约的重算任务（含已失败的任务）；from 非零时先按 from 创建（或合并）一个手工任务
+func (s *Service) Recompute(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	from time.Time,
+) ([]models.RecomputeJob, error) {
+
+	var c models.SysContract
+	err := s.db.WithContext(ctx).
+		Where("chain_id=? AND address=?", chainID, contract).
+		First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("contract %s on chain %d not configured", contract, chainID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !from.IsZero() {
+		if _, err := repository.EnqueueRecompute(
+			ctx,
+			s.db,
+			c.ChainID,
+			c.Address,
+			"",
+			from,
+			repository.RecomputeReasonManual,
+			0,
+		); err != nil {
+			return nil, fmt.Errorf("enqueue recompute failed: %w", err)
+		}
+	}
+
+	l, ok, err := s.leases.Acquire(ctx, lease.CalculatorName(c.ChainID, c.Address))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCalculatorBusy
+	}
+	defer s.leases.ReleaseLease(context.WithoutCancel(ctx), l)
+
+	// 手工执行时失败的任务不受退避和次数限制
+	return s.runRecomputeJobs(lease.WithLease(ctx, l), c, true)
+}
+
+// runRecomputeJobs 按创建顺序执行合约未完成的重算任务（需已持有合约租约）
+// 失败的任务到达 next_retry_at 且未超过重试次数时重新执行；force 时全部重新执行
+func (s *Service) runRecomputeJobs(
+	ctx context.Context,
+	contract models.SysContract,
+	force bool,
+) ([]models.RecomputeJob, error) {
+
+	q := s.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=?", contract.ChainID, contract.Address)
+	if force {
+		q = q.Where(
+			"status IN ?",
+			[]string{models.RecomputePending, models.RecomputeRunning, models.RecomputeFailed},
+		)
+	} else {
+		q = q.Where(
+			"(status IN ? OR (status=? AND attempts < ? AND next_retry_at <= ?))",
+			[]string{models.RecomputePending, models.RecomputeRunning},
+			models.RecomputeFailed, recomputeMaxAttempts, time.Now().UTC(),
+		)
+	}
+
+	var jobs []models.RecomputeJob
+	if err := q.Order("id ASC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		if err := s.runRecomputeJob(ctx, contract, &jobs[i]); err != nil {
+			return jobs, fmt.Errorf("recompute job %d failed: %w", jobs[i].ID, err)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *Service) runRecomputeJob(
+	ctx context.Context,
+	contract models.SysContract,
+	job *models.RecomputeJob,
+) error {
+
+	chainID := contract.ChainID
+	addr := contract.Address
+	logTableName := contract.GetLogTableName()
+	from := job.FromTime.UTC()
+
+	started := time.Now().UTC()
+	if err := s.db.WithContext(ctx).
+		Model(&models.RecomputeJob{}).
+		Where("id=?", job.ID).
+		Updates(map[string]any{
+			"status":     models.RecomputeRunning,
+			"started_at": started,
+		}).Error; err != nil {
+		return err
+	}
+	job.Status = models.RecomputeRunning
+	job.StartedAt = &started
+
+	// 查询失败时任务保持 running，下次重新执行
+	q := s.db.WithContext(ctx).
+		Model(&models.UserPoint{}).
+		Where("chain_id=? AND contract_address=? AND last_calc_time > ?", chainID, addr, from)
+	if job.Account != "" {
+		q = q.Where("account=?", job.Account)
+	}
+
+	var accounts []string
+	if err := q.Order("account ASC").Pluck("account", &accounts).Error; err != nil {
+		return err
+	}
+
+	var (
+		corrected int64
+		total     = decimal.Zero
+		err       error
+	)
+
+	for _, acct := range accounts {
+		if err = s.leases.Renew(ctx); err != nil {
+			break
+		}
+		if err = s.acquireDB(ctx); err != nil {
+			break
+		}
+
+		var delta decimal.Decimal
+		delta, err = s.recomputeAccount(ctx, contract, job, acct, logTableName)
+		s.releaseDB()
+		if err != nil {
+			err = fmt.Errorf("account %s: %w", acct, err)
+			break
+		}
+
+		if !delta.IsZero() {
+			corrected++
+			total = total.Add(delta)
+		}
+	}
+
+	finished := time.Now().UTC()
+	updates := map[string]any{
+		"accounts":     int64(len(accounts)),
+		"corrected":    corrected,
+		"points_delta": total.String(),
+		"finished_at":  finished,
+		"status":       models.RecomputeDone,
+	}
+	job.Status = models.RecomputeDone
+	if err != nil {
+		// 租约丢失时保持 running，由新持有者重新执行
+		if errors.Is(err, repository.ErrLeaseLost) {
+			return err
+		}
+		retryAt := finished.Add(recomputeBackoff(job.Attempts + 1))
+		updates["status"] = models.RecomputeFailed
+		updates["error"] = err.Error()
+		updates["attempts"] = job.Attempts + 1
+		updates["next_retry_at"] = retryAt
+		job.Status = models.RecomputeFailed
+		job.Error = err.Error()
+		job.Attempts++
+		job.NextRetryAt = &retryAt
+	}
+
+	job.Accounts = int64(len(accounts))
+	job.Corrected = corrected
+	job.PointsDelta = total.String()
+	job.FinishedAt = &finished
+
+	if uerr := s.db.WithContext(context.WithoutCancel(ctx)).
+		Model(&models.RecomputeJob{}).
+		Where("id=?", job.ID).
+		Updates(updates).Error; uerr != nil {
+		return uerr
+	}
+
+	log.Printf("[calculator] recompute job=%d chain=%d contract=%s from=%s accounts=%d corrected=%d delta=%s",
+		job.ID, chainID, addr, from.Format(time.RFC3339), len(accounts), corrected, total.String())
+
+	return err
+}
+
+// recomputeAccount 重算单个账户 from_time 之后的积分，返回 total_points 的调整量
+func (s *Service) recomputeAccount(
+	ctx context.Context,
+	contract models.SysContract,
+	job *models.RecomputeJob,
+	account string,
+	logTableName string,
+) (decimal.Decimal, error) {
+
+	chainID := contract.ChainID
+	addr := contract.Address
+	from := job.FromTime.UTC()
+
+	delta := decimal.Zero
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+
+		// fencing：租约已易主则拒绝写入
+		if err := s.leases.CheckFence(ctx, tx); err != nil {
+			return err
+		}
+
+		// 1) 锁住 user_point，与常规计算互斥
+		var up models.UserPoint
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(
+				"chain_id=? AND contract_address=? AND account=?",
+				chainID, addr, account,
+			).
+			First(&up).Error; err != nil {
+			return err
+		}
+
+		t1 := up.LastCalcTime.UTC()
+		if !from.Before(t1) {
+			return nil
+		}
+
+		// 2) 受影响的日志：to_time > from（跨过 from 的那段从其起点整段重算）
+		affected := tx.Table(logTableName).
+			Where(
+				"chain_id=? AND contract_address=? AND account=? AND to_time > ?",
+				chainID, addr, account, from,
+			)
+
+		var old struct {
+			Points   decimal.NullDecimal
+			FromTime *time.Time
+		}
+		if err := affected.Session(&gorm.Session{}).
+			Select("SUM(points) AS points, MIN(from_time) AS from_time").
+			Scan(&old).Error; err != nil {
+			return err
+		}
+
+		t0 := from
+		if old.FromTime != nil && old.FromTime.UTC().Before(t0) {
+			t0 = old.FromTime.UTC()
+		}
+		oldPoints := decimal.Zero
+		if old.Points.Valid {
+			oldPoints = old.Points.Decimal
+		}
+
+		// 3) 按当前规则重新分段计算 [t0, last_calc_time)
+		pd, err := ComputePointsDelta(
+			ctx,
+			tx,
+			chainID,
+			addr,
+			account,
+			contract.BalanceDecimals(),
+			t0,
+			t1,
+		)
+		if err != nil {
+			return fmt.Errorf("compute points failed: %w", err)
+		}
+
+		newPoints := pd.Total
+		if newPoints.IsNegative() {
+			newPoints = decimal.Zero
+		}
+
+		// 4) 替换日志
+		if err := affected.Session(&gorm.Session{}).
+			Delete(&models.UserPointLog{}).Error; err != nil {
+			return err
+		}
+
+		nowUTC := time.Now().UTC()
+		if err := insertPointSegments(tx, logTableName, chainID, addr, account, pd.Segments, nowUTC); err != nil {
+			return err
+		}
+
+		delta = newPoints.Sub(oldPoints)
+		if delta.IsZero() {
+			return nil
+		}
+
+		// 5) 调整快照并记录审计
+		total, err := decimal.NewFromString(up.TotalPoints)
+		if err != nil {
+			return fmt.Errorf("invalid total_points in db: %w", err)
+		}
+
+		if err := tx.Model(&models.UserPoint{}).
+			Where("id=?", up.ID).
+			Updates(map[string]any{
+				"total_points": total.Add(delta).String(),
+				"updated_at":   nowUTC,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PointCorrection{
+			JobID:           job.ID,
+			ChainID:         chainID,
+			ContractAddress: addr,
+			Account:         account,
+			FromTime:        t0,
+			ToTime:          t1,
+			OldPoints:       oldPoints.String(),
+			NewPoints:       newPoints.String(),
+			Delta:           delta.String(),
+			CreatedAt:       nowUTC,
+		}).Error
+	})
+
+	return delta, err
+}
@@ -0,0 +1,253 @@
+package calculator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+)
+
+/*
+Run ledger
+----------
+- 每个合约每次实际计算写一条 calc_run（safe 时间未推进被跳过的不记录）
+- 分段计算失败的账户在本轮末尾重试 failedRetryAttempts 次
+- 仍失败的账户 last_calc_time 不推进，本轮记为 partial，下一轮会再次计算
+*/
+
+const (
+	failedRetryAttempts = 2
+	failedRetryDelay    = 2 * time.Second
+
+	// 单次运行最多保留的错误样本
+	maxErrorSamples = 20
+)
+
+// ErrAccountsFailed 本轮有账户重试后仍计算失败
+var ErrAccountsFailed = errors.New("accounts failed")
+
+// ErrorSample calc_run.error_samples 中的一条
+type ErrorSample struct {
+	Account string `json:"account"`
+	Error   string `json:"error"`
+}
+
+// runStats 单次运行的统计，分片 goroutine 并发写入
+type runStats struct {
+	mu sync.Mutex
+
+	idle      int64
+	processed int64
+	points    decimal.Decimal
+
+	// 失败账户 -> 最近一次错误
+	failed map[string]error
+}
+
+func newRunStats() *runStats {
+	return &runStats{failed: make(map[string]error)}
+}
+
+func (st *runStats) addIdle(n int64, points decimal.Decimal) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.idle += n
+	st.points = st.points.Add(points)
+}
+
+func (st *runStats) ok(account string, points decimal.Decimal) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.processed++
+	st.points = st.points.Add(points)
+	delete(st.failed, account)
+}
+
+func (st *runStats) fail(account string, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.failed[account] = err
+}
+
+func (st *runStats) failedAccounts() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	out := make([]string, 0, len(st.failed))
+	for acct := range st.failed {
+		out = append(out, acct)
+	}
+	return out
+}
+
+func (st *runStats) samples() []ErrorSample {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	out := make([]ErrorSample, 0, min(len(st.failed), maxErrorSamples))
+	for acct, err := range st.failed {
+		if len(out) >= maxErrorSamples {
+			break
+		}
+		out = append(out, ErrorSample{Account: acct, Error: err.Error()})
+	}
+	return out
+}
+
+// retryFailed 重试本轮失败的账户
+func (s *Service) retryFailed(
+	ctx context.Context,
+	contract models.SysContract,
+	now time.Time,
+	logTableName string,
+	stats *runStats,
+) error {
+
+	for attempt := 1; attempt <= failedRetryAttempts; attempt++ {
+		accounts := stats.failedAccounts()
+		if len(accounts) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(failedRetryDelay):
+		}
+
+		log.Printf("[calculator] retry chain=%d contract=%s accounts=%d attempt=%d",
+			contract.ChainID, contract.Address, len(accounts), attempt)
+
+		for _, acct := range accounts {
+			if err := s.leases.Renew(ctx); err != nil {
+				return err
+			}
+			if _, err := s.calcAccount(ctx, contract, acct, now, logTableName, stats); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// calcAccount 计算单个账户并记入统计，返回是否成功；只有租约丢失 / ctx 结束才返回错误
+func (s *Service) calcAccount(
+	ctx context.Context,
+	contract models.SysContract,
+	account string,
+	now time.Time,
+	logTableName string,
+	stats *runStats,
+) (bool, error) {
+
+	if err := s.acquireDB(ctx); err != nil {
+		return false, err
+	}
+	points, err := s.calcOneAccount(
+		ctx,
+		contract.ChainID,
+		contract.Address,
+		account,
+		contract.BalanceDecimals(),
+		now,
+		logTableName,
+	)
+	s.releaseDB()
+
+	if err != nil {
+		if errors.Is(err, repository.ErrLeaseLost) || ctx.Err() != nil {
+			return false, err
+		}
+		log.Printf("[ERROR] calcOneAccount failed: chain=%d contract=%s account=%s err=%v",
+			contract.ChainID, contract.Address, account, err)
+		stats.fail(account, err)
+		return false, nil
+	}
+
+	stats.ok(account, points)
+	return true, nil
+}
+
+// startRun 写入 running 状态的 calc_run
+func (s *Service) startRun(
+	ctx context.Context,
+	contract models.SysContract,
+	safeT time.Time,
+) (*models.CalcRun, error) {
+
+	run := &models.CalcRun{
+		ChainID:         contract.ChainID,
+		ContractAddress: contract.Address,
+		SafeTime:        safeT.UTC(),
+		StartedAt:       time.Now().UTC(),
+		Status:          models.CalcRunRunning,
+		PointsIssued:    "0",
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// finishRun 按统计与错误结束 calc_run，返回本轮对调用方的错误
+func (s *Service) finishRun(
+	ctx context.Context,
+	run *models.CalcRun,
+	stats *runStats,
+	runErr error,
+) error {
+
+	samples := stats.samples()
+
+	stats.mu.Lock()
+	failed := int64(len(stats.failed))
+	updates := map[string]any{
+		"idle_accounts":      stats.idle,
+		"accounts_processed": stats.processed,
+		"accounts_failed":    failed,
+		"points_issued":      stats.points.String(),
+	}
+	stats.mu.Unlock()
+
+	status := models.CalcRunSuccess
+	switch {
+	case runErr != nil:
+		status = models.CalcRunFailed
+		updates["error"] = runErr.Error()
+	case failed > 0:
+		status = models.CalcRunPartial
+		runErr = fmt.Errorf("%w: %d chain=%d contract=%s", ErrAccountsFailed, failed, run.ChainID, run.ContractAddress)
+	}
+
+	if len(samples) > 0 {
+		data, err := json.Marshal(samples)
+		if err == nil {
+			updates["error_samples"] = data
+		}
+	}
+
+	finished := time.Now().UTC()
+	updates["status"] = status
+	updates["finished_at"] = finished
+
+	// ctx 已取消时也要把结果落库
+	if err := s.db.WithContext(context.WithoutCancel(ctx)).
+		Model(&models.CalcRun{}).
+		Where("id=?", run.ID).
+		Updates(updates).Error; err != nil {
+		log.Printf("[ERROR] finish calc_run id=%d failed: %v", run.ID, err)
+	}
+
+	return runErr
+}
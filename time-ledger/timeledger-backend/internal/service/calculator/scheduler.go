@@ -0,0 +1,211 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/schedule"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/events"
+)
+
+/*
+Scheduler
+---------
+- 每个合约按 sys_contract.calc_schedule 独立调度（cron / @every，空为 @hourly）
+- calc_trigger = event 的合约收到 CursorAdvanced 后，防抖 eventDebounce 再计算
+- 同一合约同一时刻只跑一个，合约间并发受 calculator.contract_workers 限制
+- safe block 时间未推进的合约直接跳过（见 runContractWorker）
+- ctx 取消后等待进行中的计算结束，释放本实例持有的全部租约
+*/
+
+const (
+	// schedulerTick 调度检查间隔，同时用于刷新合约配置
+	schedulerTick = 10 * time.Second
+
+	// eventDebounce 合并短时间内的多次 cursor 推进
+	eventDebounce = 10 * time.Second
+)
+
+// contractState 单个合约的调度状态（只在调度 goroutine 内读写）
+type contractState struct {
+	contract models.SysContract
+
+	spec     string
+	sched    schedule.Schedule
+	next     time.Time
+	eventDue time.Time
+	running  bool
+}
+
+func contractKey(chainID int64, addr string) string {
+	return fmt.Sprintf("%d:%s", chainID, strings.ToLower(addr))
+}
+
+// StartHourly：每小时整点跑一次（等价于所有合约使用 @hourly 且不订阅事件）
+func (s *Service) StartHourly(ctx context.Context) {
+	s.Start(ctx, nil)
+}
+
+// Start 按合约调度计算，bus 为 nil 时只按 calc_schedule 定时运行
+func (s *Service) Start(ctx context.Context, bus events.Bus) {
+	var evCh <-chan events.CursorAdvanced
+	if bus != nil {
+		ch, err := bus.SubscribeCursorAdvanced(ctx)
+		if err != nil {
+			log.Printf("[calculator] subscribe cursor_advanced failed, schedule only: %v", err)
+		} else {
+			evCh = ch
+		}
+	}
+
+	workers := s.cfg.Calculator.ContractWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	done := make(chan string, 64)
+
+	states := make(map[string]*contractState)
+	s.refreshStates(ctx, states, time.Now().UTC())
+
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	// 退出时等待进行中的合约计算结束，再释放租约，其他副本无需等待过期即可接管
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		s.leases.Release(context.WithoutCancel(ctx))
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case key := <-done:
+			if st, ok := states[key]; ok {
+				st.running = false
+			}
+
+		case ev, ok := <-evCh:
+			if !ok {
+				evCh = nil
+				continue
+			}
+			st, found := states[contractKey(ev.ChainID, ev.Contract)]
+			if !found || st.contract.CalcTrigger != models.CalcTriggerEvent {
+				continue
+			}
+			// 已有待触发的事件时不再推迟，避免持续推进时一直不计算
+			if st.eventDue.IsZero() {
+				st.eventDue = time.Now().UTC().Add(eventDebounce)
+			}
+
+		case <-ticker.C:
+			now := time.Now().UTC()
+			s.refreshStates(ctx, states, now)
+
+			for key, st := range states {
+				if st.running || !st.due(now) {
+					continue
+				}
+				st.running = true
+				st.eventDue = time.Time{}
+				if st.sched != nil {
+					st.next = st.sched.Next(now)
+				}
+
+				key, c := key, st.contract
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() {
+						select {
+						case done <- key:
+						case <-ctx.Done():
+						}
+					}()
+
+					select {
+					case sem <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+					defer func() { <-sem }()
+
+					if err := s.runContractWorker(ctx, c); err != nil {
+						log.Printf("[ERROR] %v", err)
+					}
+				}()
+			}
+		}
+	}
+}
+
+// due 定时或事件任一到期即需要计算
+func (st *contractState) due(now time.Time) bool {
+	if !st.next.IsZero() && !now.Before(st.next) {
+		return true
+	}
+	return !st.eventDue.IsZero() && !now.Before(st.eventDue)
+}
+
+// refreshStates 重新加载 Active 合约，schedule 变化时重算下次运行时间
+func (s *Service) refreshStates(
+	ctx context.Context,
+	states map[string]*contractState,
+	now time.Time,
+) {
+	contracts, err := repository.GetActiveContracts(ctx, s.db)
+	if err != nil {
+		log.Printf("[calculator] load active contracts failed: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool, len(contracts))
+	for _, c := range contracts {
+		// decimals 未与链上核对前不计算，避免按错误精度累计积分
+		if !c.IsNFT() && !c.DecimalsVerified {
+			continue
+		}
+
+		key := contractKey(c.ChainID, c.Address)
+		seen[key] = true
+
+		st, ok := states[key]
+		if !ok {
+			st = &contractState{}
+			states[key] = st
+		}
+		st.contract = c
+
+		if ok && st.spec == c.CalcSchedule {
+			continue
+		}
+
+		sched, err := schedule.Parse(c.CalcSchedule)
+		if err != nil {
+			// 配置校验已拦截，DB 被手工修改时退回默认调度
+			log.Printf("[calculator] invalid calc_schedule %q chain=%d contract=%s: %v",
+				c.CalcSchedule, c.ChainID, c.Address, err)
+			sched, _ = schedule.Parse(schedule.Default)
+		}
+		st.spec = c.CalcSchedule
+		st.sched = sched
+		st.next = sched.Next(now)
+	}
+
+	// 已停用的合约：运行中的等结束后再移除
+	for key, st := range states {
+		if !seen[key] && !st.running {
+			delete(states, key)
+		}
+	}
+}
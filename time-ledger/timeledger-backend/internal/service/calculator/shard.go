@@ -0,0 +1,249 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Shard
+-----
+- 一次计算（run_time = 结算上界）把需要分段计算的账户按账户地址排序切成分片，写入 calc_shard
+- 分片由 shard_workers 个 goroutine 并发处理，每处理一批账户记录一次 last_account
+- 进程崩溃后，下一轮先按原 run_time 续跑未完成的分片（从 last_account 之后继续），再开始新一轮
+- 所有积分写事务共用一个全局信号量（db_concurrency），避免多合约 × 多分片打满连接池
+*/
+
+const (
+	defaultShardSize = 1000
+
+	// 分片内每批读取的账户数（每批结束记录一次进度）
+	shardBatchSize = 200
+)
+
+// acquireDB 占用一个 DB 并发名额
+func (s *Service) acquireDB(ctx context.Context) error {
+	select {
+	case s.dbSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Service) releaseDB() { <-s.dbSem }
+
+// unfinishedRun 该合约早于 now 的、仍有未完成分片的 run_time
+func (s *Service) unfinishedRun(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	now time.Time,
+) (time.Time, bool, error) {
+
+	var shard models.CalcShard
+	err := s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND status=? AND run_time < ?",
+			chainID, contract, models.CalcShardPending, now.UTC(),
+		).
+		Order("run_time ASC").
+		Limit(1).
+		Find(&shard).Error
+	if err != nil || shard.ID == 0 {
+		return time.Time{}, false, err
+	}
+	return shard.RunTime.UTC(), true, nil
+}
+
+// planShards 为 runTime 切分片；已切过（续跑）时直接返回已有分片
+func (s *Service) planShards(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	runTime time.Time,
+) ([]models.CalcShard, error) {
+
+	var shards []models.CalcShard
+	if err := s.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=? AND run_time=?", chainID, contract, runTime).
+		Order("shard_no ASC").
+		Find(&shards).Error; err != nil {
+		return nil, err
+	}
+	if len(shards) > 0 {
+		return shards, nil
+	}
+
+	accounts, err := s.pendingAccounts(ctx, chainID, contract, runTime)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+
+	size := s.cfg.Calculator.ShardSize
+	if size <= 0 {
+		size = defaultShardSize
+	}
+
+	for i := 0; i < len(accounts); i += size {
+		end := i + size
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		shards = append(shards, models.CalcShard{
+			ChainID:         chainID,
+			ContractAddress: contract,
+			RunTime:         runTime,
+			ShardNo:         len(shards),
+			FromAccount:     accounts[i],
+			ToAccount:       accounts[end-1],
+			Status:          models.CalcShardPending,
+		})
+	}
+
+	// 多副本并发切分时以先写入者为准
+	if err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(&shards, 500).Error; err != nil {
+		return nil, err
+	}
+
+	shards = shards[:0]
+	err = s.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=? AND run_time=?", chainID, contract, runTime).
+		Order("shard_no ASC").
+		Find(&shards).Error
+	return shards, err
+}
+
+// runShards 并发处理 runTime 的全部未完成分片，全部完成后清理更早的分片记录
+func (s *Service) runShards(
+	ctx context.Context,
+	contract models.SysContract,
+	runTime time.Time,
+	logTableName string,
+	stats *runStats,
+) error {
+
+	chainID := contract.ChainID
+	addr := contract.Address
+
+	shards, err := s.planShards(ctx, chainID, addr, runTime)
+	if err != nil {
+		return fmt.Errorf("plan shards failed chain=%d contract=%s: %w", chainID, addr, err)
+	}
+
+	workers := s.cfg.Calculator.ShardWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+
+	for _, shard := range shards {
+		if shard.Status == models.CalcShardDone {
+			continue
+		}
+		shard := shard
+
+		g.Go(func() error {
+			return s.runShard(gctx, contract, shard, logTableName, stats)
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// 本轮全部完成：只保留最近一轮的分片记录
+	return s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND run_time < ?",
+			chainID, addr, runTime,
+		).
+		Delete(&models.CalcShard{}).Error
+}
+
+// runShard 按账户顺序分批处理一个分片，每批结束记录进度
+func (s *Service) runShard(
+	ctx context.Context,
+	contract models.SysContract,
+	shard models.CalcShard,
+	logTableName string,
+	stats *runStats,
+) error {
+
+	chainID := contract.ChainID
+	addr := contract.Address
+	runTime := shard.RunTime.UTC()
+
+	last := shard.LastAccount
+
+	for {
+		var accounts []string
+		if err := s.db.WithContext(ctx).
+			Model(&models.UserPoint{}).
+			Where(
+				"chain_id=? AND contract_address=? AND account BETWEEN ? AND ? AND account > ? AND last_calc_time < ?",
+				chainID, addr, shard.FromAccount, shard.ToAccount, last, runTime,
+			).
+			Order("account ASC").
+			Limit(shardBatchSize).
+			Pluck("account", &accounts).Error; err != nil {
+			return err
+		}
+
+		if len(accounts) == 0 {
+			break
+		}
+
+		var processed, failed int64
+		for _, acct := range accounts {
+			// 账户多时单轮耗时长，按需续约；租约丢失则让出给新持有者
+			if err := s.leases.Renew(ctx); err != nil {
+				return err
+			}
+
+			ok, err := s.calcAccount(ctx, contract, acct, runTime, logTableName, stats)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				failed++
+				continue
+			}
+			processed++
+		}
+
+		last = accounts[len(accounts)-1]
+
+		if err := s.db.WithContext(ctx).
+			Model(&models.CalcShard{}).
+			Where("id=?", shard.ID).
+			Updates(map[string]any{
+				"last_account": last,
+				"processed":    gorm.Expr("processed + ?", processed),
+				"failed":       gorm.Expr("failed + ?", failed),
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	return s.db.WithContext(ctx).
+		Model(&models.CalcShard{}).
+		Where("id=?", shard.ID).
+		Updates(map[string]any{
+			"status": models.CalcShardDone,
+		}).Error
+}
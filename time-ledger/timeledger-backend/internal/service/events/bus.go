@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+Events
+------
+- indexer 推进 cursor（新的 safe 数据落库）后发布 CursorAdvanced
+- calculator 订阅后对 calc_trigger = event 的合约尽快触发计算
+- 单进程部署用 LocalBus；indexer 与 calculator 分开部署时用 RedisBus（pub/sub）
+- 通知只是加速手段，丢失不影响正确性：定时调度仍会兜底
+*/
+
+// CursorAdvanced 合约 cursor 已推进
+type CursorAdvanced struct {
+	ChainID     int64     `json:"chain_id"`
+	Contract    string    `json:"contract"`
+	BlockNumber int64     `json:"block_number"`
+	At          time.Time `json:"at"`
+}
+
+// Bus 事件总线
+type Bus interface {
+	PublishCursorAdvanced(ctx context.Context, ev CursorAdvanced) error
+
+	// SubscribeCursorAdvanced 订阅直到 ctx 结束，结束后关闭 channel
+	SubscribeCursorAdvanced(ctx context.Context) (<-chan CursorAdvanced, error)
+}
+
+// subscriberBuffer 每个订阅者的缓冲，满了丢弃（订阅方处理慢时不阻塞 indexer）
+const subscriberBuffer = 256
+
+// LocalBus 进程内事件总线
+type LocalBus struct {
+	mu   sync.Mutex
+	subs map[chan CursorAdvanced]struct{}
+}
+
+func NewLocalBus() *LocalBus {
+	return &LocalBus{subs: make(map[chan CursorAdvanced]struct{})}
+}
+
+func (b *LocalBus) PublishCursorAdvanced(_ context.Context, ev CursorAdvanced) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *LocalBus) SubscribeCursorAdvanced(ctx context.Context) (<-chan CursorAdvanced, error) {
+	ch := make(chan CursorAdvanced, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, ch)
+		close(ch)
+		b.mu.Unlock()
+	}()
+
+	return ch, nil
+}
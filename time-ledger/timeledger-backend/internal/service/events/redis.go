@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBus 基于 Redis pub/sub 的事件总线，用于 indexer / calculator 分开部署
+type RedisBus struct {
+	rdb     *redis.Client
+	channel string
+}
+
+func NewRedisBus(rdb *redis.Client, keyPrefix string) *RedisBus {
+	return &RedisBus{
+		rdb:     rdb,
+		channel: fmt.Sprintf("%s:events:cursor_advanced", keyPrefix),
+	}
+}
+
+// NewBus 配置了 Redis 时用 RedisBus，否则退回进程内 LocalBus
+func NewBus(rdb *redis.Client, keyPrefix string) Bus {
+	if rdb == nil {
+		return NewLocalBus()
+	}
+	return NewRedisBus(rdb, keyPrefix)
+}
+
+func (b *RedisBus) PublishCursorAdvanced(ctx context.Context, ev CursorAdvanced) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return b.rdb.Publish(ctx, b.channel, data).Err()
+}
+
+func (b *RedisBus) SubscribeCursorAdvanced(ctx context.Context) (<-chan CursorAdvanced, error) {
+	sub := b.rdb.Subscribe(ctx, b.channel)
+
+	// 等待订阅确认，连接失败时直接返回错误
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+
+	out := make(chan CursorAdvanced, subscriberBuffer)
+
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+
+				var ev CursorAdvanced
+				if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+					log.Printf("[events] decode cursor_advanced failed: %v", err)
+					continue
+				}
+
+				select {
+				case out <- ev:
+				default:
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
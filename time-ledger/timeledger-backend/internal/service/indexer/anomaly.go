@@ -0,0 +1,130 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Anomaly
+-------
+- 负余额等异常不再一律让整个 chunk 回滚重试
+- 按合约配置的策略处理：halt / clamp / skip
+- 不论哪种策略，异常事件及上下文都会落到 indexer_anomaly
+*/
+
+// AnomalyError 表示 halt 策略下被中断的异常
+// chunk 事务回滚后，由 applyChunkTx 在事务外补记 anomaly
+type AnomalyError struct {
+	Anomaly models.IndexerAnomaly
+}
+
+func (e *AnomalyError) Error() string {
+	return fmt.Sprintf(
+		"%s: acct=%s bal=%s delta=%s block=%d log=%d",
+		e.Anomaly.Kind,
+		e.Anomaly.Account,
+		e.Anomaly.BalanceBefore,
+		e.Anomaly.Delta,
+		e.Anomaly.BlockNumber,
+		e.Anomaly.LogIndex,
+	)
+}
+
+// anomalyPolicyOf 返回合约的异常处理策略，未配置时按 halt 处理
+func anomalyPolicyOf(contract models.SysContract) string {
+	switch contract.AnomalyPolicy {
+	case models.AnomalyPolicyClamp, models.AnomalyPolicySkip:
+		return contract.AnomalyPolicy
+	default:
+		return models.AnomalyPolicyHalt
+	}
+}
+
+// newNegativeBalanceAnomaly 构造负余额异常记录
+func newNegativeBalanceAnomaly(
+	chainID int64,
+	contract models.SysContract,
+	ev TransferEvent,
+	account common.Address,
+	before, delta, after *big.Int,
+) models.IndexerAnomaly {
+
+	return models.IndexerAnomaly{
+		ChainID:         chainID,
+		ContractAddress: contract.Address,
+		Account:         account.Hex(),
+		Kind:            models.AnomalyKindNegativeBalance,
+		Policy:          anomalyPolicyOf(contract),
+		BalanceBefore:   before.String(),
+		Delta:           delta.String(),
+		BalanceAfter:    after.String(),
+		BlockNumber:     int64(ev.BlockNumber),
+		BlockTime:       ev.BlockTime,
+		TxHash:          ev.TxHash.Hex(),
+		LogIndex:        int64(ev.LogIndex),
+		FromAddress:     ev.From.Hex(),
+		ToAddress:       ev.To.Hex(),
+		Value:           ev.Value.String(),
+		CreatedAt:       time.Now().UTC(),
+	}
+}
+
+// newNegativeHoldingAnomaly 构造 NFT 持仓为负的异常记录（余额字段为该 tokenId 的持仓）
+func newNegativeHoldingAnomaly(
+	chainID int64,
+	contract models.SysContract,
+	ev TransferEvent,
+	account common.Address,
+	tokenID *big.Int,
+	before, delta, after *big.Int,
+) models.IndexerAnomaly {
+
+	a := newNegativeBalanceAnomaly(chainID, contract, ev, account, before, delta, after)
+	a.Kind = models.AnomalyKindNegativeHolding
+	a.TokenID = tokenID.String()
+	return a
+}
+
+// recordAnomaly 写入 indexer_anomaly（幂等）
+func recordAnomaly(ctx context.Context, db *gorm.DB, a *models.IndexerAnomaly) error {
+	log.Printf(
+		"[indexer.anomaly] chain=%d contract=%s kind=%s policy=%s acct=%s token=%s bal=%s delta=%s block=%d log=%d",
+		a.ChainID,
+		a.ContractAddress,
+		a.Kind,
+		a.Policy,
+		a.Account,
+		a.TokenID,
+		a.BalanceBefore,
+		a.Delta,
+		a.BlockNumber,
+		a.LogIndex,
+	)
+
+	return db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(a).Error
+}
+
+// recordHaltedAnomaly 如果 err 是 halt 策略产生的 AnomalyError，在事务外补记
+func (ix *Indexer) recordHaltedAnomaly(ctx context.Context, err error) {
+	var ae *AnomalyError
+	if !errors.As(err, &ae) {
+		return
+	}
+
+	if rerr := recordAnomaly(ctx, ix.db, &ae.Anomaly); rerr != nil {
+		log.Printf("[indexer.anomaly] record failed: %v", rerr)
+	}
+}
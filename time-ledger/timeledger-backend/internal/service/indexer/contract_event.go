@@ -0,0 +1,241 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Contract Events
+---------------
+- 合约配置 ABI + 事件名后，无需写 Go 代码即可索引任意事件
+- topic0 并入 chunk 的同一次 eth_getLogs，与 Transfer 共享 cursor / pending / reorg / 幂等
+- 解码结果写入 contract_event（args JSON + topic1~3 原始值）
+*/
+
+// eventSpec 合约配置的通用事件，按 topic0 查找
+type eventSpec struct {
+	byID map[common.Hash]abi.Event
+}
+
+// parseEventSpec 从 SysContract 解析通用事件配置，未配置时返回 nil
+func parseEventSpec(c models.SysContract) (*eventSpec, error) {
+	if strings.TrimSpace(c.EventNames) == "" {
+		return nil, nil
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(c.EventABI))
+	if err != nil {
+		return nil, fmt.Errorf("parse abi contract=%s: %w", c.Address, err)
+	}
+
+	spec := &eventSpec{byID: make(map[common.Hash]abi.Event)}
+	for _, name := range strings.Split(c.EventNames, ",") {
+		name = strings.TrimSpace(name)
+		ev, ok := parsed.Events[name]
+		if !ok {
+			return nil, fmt.Errorf("event %s not found in abi contract=%s", name, c.Address)
+		}
+		// 匿名事件没有签名 topic，无法按 topic0 过滤
+		if ev.Anonymous {
+			return nil, fmt.Errorf("anonymous event %s is not supported contract=%s", name, c.Address)
+		}
+		spec.byID[ev.ID] = ev
+	}
+	return spec, nil
+}
+
+// topics 返回全部事件的 topic0
+func (s *eventSpec) topics() []common.Hash {
+	if s == nil {
+		return nil
+	}
+	out := make([]common.Hash, 0, len(s.byID))
+	for id := range s.byID {
+		out = append(out, id)
+	}
+	return out
+}
+
+// lookup 按 topic0 查找事件定义
+func (s *eventSpec) lookup(topic0 common.Hash) (abi.Event, bool) {
+	if s == nil {
+		return abi.Event{}, false
+	}
+	ev, ok := s.byID[topic0]
+	return ev, ok
+}
+
+// ContractLogEvent 解码后的通用事件
+// Args 已是 JSON，可直接进 Redis pending 与 contract_event
+type ContractLogEvent struct {
+	BlockNumber uint64
+	LogIndex    uint
+	TxHash      common.Hash
+	EventName   string
+	Topics      []common.Hash
+	Args        json.RawMessage
+	BlockTime   time.Time
+}
+
+// decodeContractLog 按 ABI 解码 log：非 indexed 参数来自 data，indexed 参数来自 topics
+// indexed 的动态类型（string / bytes / 数组）链上只保留 keccak，解码结果为 hash
+func decodeContractLog(ev abi.Event, lg types.Log, blockTime time.Time) (ContractLogEvent, error) {
+	args := make(map[string]any)
+
+	if err := ev.Inputs.UnpackIntoMap(args, lg.Data); err != nil {
+		return ContractLogEvent{}, fmt.Errorf("unpack %s data: %w", ev.Name, err)
+	}
+
+	var indexed abi.Arguments
+	for _, in := range ev.Inputs {
+		if in.Indexed {
+			indexed = append(indexed, in)
+		}
+	}
+	if len(lg.Topics) != len(indexed)+1 {
+		return ContractLogEvent{}, fmt.Errorf(
+			"event %s expects %d topics, got %d",
+			ev.Name, len(indexed)+1, len(lg.Topics),
+		)
+	}
+	if err := abi.ParseTopicsIntoMap(args, indexed, lg.Topics[1:]); err != nil {
+		return ContractLogEvent{}, fmt.Errorf("parse %s topics: %w", ev.Name, err)
+	}
+
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		out[k] = normalizeABIValue(reflect.ValueOf(v))
+	}
+
+	raw, err := json.Marshal(out)
+	if err != nil {
+		return ContractLogEvent{}, err
+	}
+
+	return ContractLogEvent{
+		BlockNumber: lg.BlockNumber,
+		LogIndex:    lg.Index,
+		TxHash:      lg.TxHash,
+		EventName:   ev.Name,
+		Topics:      lg.Topics,
+		Args:        raw,
+		BlockTime:   blockTime,
+	}, nil
+}
+
+var (
+	bigIntType  = reflect.TypeOf((*big.Int)(nil))
+	addressType = reflect.TypeOf(common.Address{})
+	hashType    = reflect.TypeOf(common.Hash{})
+)
+
+// normalizeABIValue 把 ABI 解码值转换为 JSON 友好的形式
+// - 整数（含 uint256）统一为十进制字符串，避免 JSON number 精度丢失
+// - address / hash / bytes 为 0x 开头的 hex
+// - tuple 按 json tag（即 ABI 字段名）转为对象
+func normalizeABIValue(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Type() {
+	case bigIntType:
+		if v.IsNil() {
+			return nil
+		}
+		return v.Interface().(*big.Int).String()
+	case addressType:
+		return v.Interface().(common.Address).Hex()
+	case hashType:
+		return v.Interface().(common.Hash).Hex()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d", v.Uint())
+
+	case reflect.Slice, reflect.Array:
+		// bytes / bytesN
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			for i := range b {
+				b[i] = byte(v.Index(i).Uint())
+			}
+			return hexutil.Encode(b)
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = normalizeABIValue(v.Index(i))
+		}
+		return out
+
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			name := f.Tag.Get("json")
+			if name == "" {
+				name = f.Name
+			}
+			out[name] = normalizeABIValue(v.Field(i))
+		}
+		return out
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return normalizeABIValue(v.Elem())
+	}
+
+	return v.Interface()
+}
+
+// applyContractEvent 写 contract_event（幂等）
+func applyContractEvent(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	ev ContractLogEvent,
+) error {
+
+	row := models.ContractEvent{
+		ChainID:         chainID,
+		ContractAddress: contract,
+		EventName:       ev.EventName,
+		Topic0:          ev.Topics[0].Hex(),
+		Args:            ev.Args,
+		BlockNumber:     int64(ev.BlockNumber),
+		BlockTime:       ev.BlockTime,
+		TxHash:          ev.TxHash.Hex(),
+		LogIndex:        int64(ev.LogIndex),
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	for i, dst := range []**string{&row.Topic1, &row.Topic2, &row.Topic3} {
+		if i+1 < len(ev.Topics) {
+			t := ev.Topics[i+1].Hex()
+			*dst = &t
+		}
+	}
+
+	return tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&row).Error
+}
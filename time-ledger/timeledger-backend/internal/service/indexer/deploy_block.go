@@ -0,0 +1,259 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Deployment Block
+----------------
+- config 未填写 start_block（0）时，在首次同步前自动探测部署区块
+- 对 eth_getCode 做二分：找到最小的、合约代码非空的区块（需要 archive 节点）
+- 再用 Transfer log 交叉校验：在 [0, latest] 中找出合约的第一条 Transfer
+  - 先整段查询，节点拒绝（区块范围 / 结果条数超限）时二分拆段，先查前半段，最小拆到一个 chunk
+  - 第一条 Transfer 早于部署区块，说明探测结果偏晚（裁剪节点、自毁后重新部署）：
+    探测失败，需手工配置 start_block
+- 结果写入 sys_contracts.start_block / deployment_block 并标记 start_block_resolved，之后不再探测
+  （创世区块部署时 start_block 为 0，不能据此判断是否已探测）
+*/
+
+// onboardStartBlock 探测部署区块并作为 start_block
+func (ix *Indexer) onboardStartBlock(
+	ctx context.Context,
+	client *ethclient.Client,
+	chain models.SysChain,
+	contract models.SysContract,
+) (models.SysContract, error) {
+
+	addr := common.HexToAddress(contract.Address)
+
+	deployed, latest, err := ix.findDeploymentBlock(ctx, client, chain.ChainID, addr)
+	if err != nil {
+		return contract, fmt.Errorf(
+			"detect deployment block contract=%s (set start_block manually if the rpc is not an archive node): %w",
+			contract.Address, err,
+		)
+	}
+
+	if err := ix.crossCheckFirstTransfer(ctx, client, chain, addr, deployed, latest); err != nil {
+		return contract, err
+	}
+
+	if err := ix.db.WithContext(ctx).
+		Model(&models.SysContract{}).
+		Where("id=?", contract.ID).
+		Updates(map[string]any{
+			"start_block":          int64(deployed),
+			"deployment_block":     int64(deployed),
+			"start_block_resolved": true,
+			"updated_at":           time.Now().UTC(),
+		}).Error; err != nil {
+		return contract, err
+	}
+
+	log.Printf(
+		"[indexer.onboard] chain=%d contract=%s start_block=deployment_block=%d",
+		chain.ChainID,
+		contract.Address,
+		deployed,
+	)
+
+	contract.StartBlock = int64(deployed)
+	contract.DeploymentBlock = int64(deployed)
+	contract.StartBlockResolved = true
+	return contract, nil
+}
+
+// findDeploymentBlock 二分 eth_getCode，返回最早有代码的区块及探测时的最新区块
+func (ix *Indexer) findDeploymentBlock(
+	ctx context.Context,
+	client *ethclient.Client,
+	chainID int64,
+	addr common.Address,
+) (uint64, uint64, error) {
+
+	latest, err := callRPCWithRetry(
+		ctx,
+		ix.rpcLimiter,
+		"eth_blockNumber",
+		chainID,
+		0,
+		func() (uint64, error) {
+			return client.BlockNumber(ctx)
+		},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	hasCode := func(bn uint64) (bool, error) {
+		code, err := callRPCWithRetry(
+			ctx,
+			ix.rpcLimiter,
+			"eth_getCode",
+			chainID,
+			bn,
+			func() ([]byte, error) {
+				return client.CodeAt(ctx, addr, new(big.Int).SetUint64(bn))
+			},
+		)
+		return len(code) > 0, err
+	}
+
+	ok, err := hasCode(latest)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !ok {
+		return 0, 0, fmt.Errorf("no contract code at %s (latest=%d)", addr.Hex(), latest)
+	}
+
+	// 不变式：hi 处有代码
+	lo, hi := uint64(0), latest
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		ok, err := hasCode(mid)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	return lo, latest, nil
+}
+
+// crossCheckFirstTransfer 找出合约的第一条 Transfer，早于部署区块时报错
+func (ix *Indexer) crossCheckFirstTransfer(
+	ctx context.Context,
+	client *ethclient.Client,
+	chain models.SysChain,
+	addr common.Address,
+	deployed uint64,
+	latest uint64,
+) error {
+
+	ids, err := loadTokenEventIDs()
+	if err != nil {
+		return err
+	}
+
+	minSpan := uint64(chain.ChunkSize)
+	if minSpan == 0 {
+		minSpan = 10
+	}
+
+	query := func(from, to uint64) ([]types.Log, error) {
+		return callRPCWithRetry(
+			ctx,
+			ix.rpcLimiter,
+			"eth_getLogs",
+			chain.ChainID,
+			from,
+			func() ([]types.Log, error) {
+				return client.FilterLogs(ctx, ethereum.FilterQuery{
+					FromBlock: new(big.Int).SetUint64(from),
+					ToBlock:   new(big.Int).SetUint64(to),
+					Addresses: []common.Address{addr},
+					Topics:    [][]common.Hash{{ids.Transfer}},
+				})
+			},
+		)
+	}
+
+	first, err := firstLogInRange(0, latest, minSpan, query)
+	if err != nil {
+		return fmt.Errorf("search first transfer contract=%s: %w", addr.Hex(), err)
+	}
+
+	if first == nil {
+		log.Printf(
+			"[indexer.onboard] no transfer yet chain=%d contract=%s deployment=%d latest=%d",
+			chain.ChainID,
+			addr.Hex(),
+			deployed,
+			latest,
+		)
+		return nil
+	}
+
+	if first.BlockNumber < deployed {
+		return fmt.Errorf(
+			"transfer at block %d before detected deployment block %d contract=%s, set start_block manually",
+			first.BlockNumber, deployed, addr.Hex(),
+		)
+	}
+
+	log.Printf(
+		"[indexer.onboard] first transfer chain=%d contract=%s deployment=%d transfer_block=%d",
+		chain.ChainID,
+		addr.Hex(),
+		deployed,
+		first.BlockNumber,
+	)
+	return nil
+}
+
+// firstLogInRange [from, to] 内区块号最小的 log；query 因范围过大被拒绝时二分拆段，
+// 先查前半段，找到即返回；拆到 minSpan 仍失败则返回错误
+func firstLogInRange(
+	from uint64,
+	to uint64,
+	minSpan uint64,
+	query func(from, to uint64) ([]types.Log, error),
+) (*types.Log, error) {
+
+	logs, err := query(from, to)
+	if err == nil {
+		if len(logs) == 0 {
+			return nil, nil
+		}
+		first := logs[0]
+		for _, lg := range logs[1:] {
+			if lg.BlockNumber < first.BlockNumber {
+				first = lg
+			}
+		}
+		return &first, nil
+	}
+
+	if to-from+1 <= minSpan || !isLogRangeErr(err) {
+		return nil, err
+	}
+
+	mid := from + (to-from)/2
+	first, err := firstLogInRange(from, mid, minSpan, query)
+	if err != nil || first != nil {
+		return first, err
+	}
+	return firstLogInRange(mid+1, to, minSpan, query)
+}
+
+// isLogRangeErr 节点因区块范围或结果条数超限拒绝 eth_getLogs
+func isLogRangeErr(err error) bool {
+	if err == nil || errors.Is(err, ErrRateLimited) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "range") ||
+		strings.Contains(msg, "more than") ||
+		strings.Contains(msg, "exceed") ||
+		strings.Contains(msg, "limit")
+}
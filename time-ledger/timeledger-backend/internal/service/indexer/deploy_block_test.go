@@ -0,0 +1,55 @@
+package indexer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 节点限制单次查询 100 个区块：拆段后仍能找到最早的 Transfer
+func TestFirstLogInRangeSplitsOversizedQueries(t *testing.T) {
+	blocks := []uint64{730, 412, 9000}
+
+	var calls int
+	query := func(from, to uint64) ([]types.Log, error) {
+		calls++
+		if to-from+1 > 100 {
+			return nil, errors.New("query exceeds max block range 100")
+		}
+		var logs []types.Log
+		for _, bn := range blocks {
+			if bn >= from && bn <= to {
+				logs = append(logs, types.Log{BlockNumber: bn})
+			}
+		}
+		return logs, nil
+	}
+
+	first, err := firstLogInRange(0, 10_000, 10, query)
+	if err != nil {
+		t.Fatalf("first log: %v", err)
+	}
+	if first == nil || first.BlockNumber != 412 {
+		t.Fatalf("first = %v, want block 412", first)
+	}
+	if calls > 200 {
+		t.Fatalf("%d queries, expected the search to stop at the first hit", calls)
+	}
+}
+
+// 非范围错误（网络故障）不拆段，直接返回
+func TestFirstLogInRangeReturnsTransportErrors(t *testing.T) {
+	var calls int
+	query := func(from, to uint64) ([]types.Log, error) {
+		calls++
+		return nil, errors.New("dial tcp: connection refused")
+	}
+
+	if _, err := firstLogInRange(0, 10_000, 10, query); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 1 {
+		t.Fatalf("%d queries, want 1", calls)
+	}
+}
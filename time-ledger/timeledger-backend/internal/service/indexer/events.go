@@ -0,0 +1,323 @@
+package indexer
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	erc20 "github.com/Atom257/web3-labs/timeledger-backend/pkg/contract/erc20"
+)
+
+/*
+Token Events
+------------
+- 一个 chunk 只发一次 eth_getLogs，topic0 同时匹配 Transfer / Approval / OwnershipTransferred
+  以及合约配置的通用事件（见 contract_event.go）
+- 解析统一走 binding 的 ParseXxx
+- 三类事件在同一个 chunk 事务中落库，并由 reorg 回滚统一覆盖
+*/
+
+type ApprovalEvent struct {
+	BlockNumber uint64
+	LogIndex    uint
+	TxHash      common.Hash
+	Owner       common.Address
+	Spender     common.Address
+	Value       *big.Int
+	BlockTime   time.Time
+}
+
+type OwnershipEvent struct {
+	BlockNumber   uint64
+	LogIndex      uint
+	TxHash        common.Hash
+	PreviousOwner common.Address
+	NewOwner      common.Address
+	BlockTime     time.Time
+}
+
+// ChunkEvents 一个扫描区间内解析出的全部事件（各自按 block / log 升序）
+type ChunkEvents struct {
+	Transfers  []TransferEvent
+	Approvals  []ApprovalEvent
+	Ownerships []OwnershipEvent
+	Contract   []ContractLogEvent
+}
+
+// ForBlock 取出某个区块内的事件（OP Stack 按块暂存时使用）
+func (c ChunkEvents) ForBlock(bn uint64) ChunkEvents {
+	var out ChunkEvents
+	for _, ev := range c.Transfers {
+		if ev.BlockNumber == bn {
+			out.Transfers = append(out.Transfers, ev)
+		}
+	}
+	for _, ev := range c.Approvals {
+		if ev.BlockNumber == bn {
+			out.Approvals = append(out.Approvals, ev)
+		}
+	}
+	for _, ev := range c.Ownerships {
+		if ev.BlockNumber == bn {
+			out.Ownerships = append(out.Ownerships, ev)
+		}
+	}
+	for _, ev := range c.Contract {
+		if ev.BlockNumber == bn {
+			out.Contract = append(out.Contract, ev)
+		}
+	}
+	return out
+}
+
+// Len 事件总数
+func (c ChunkEvents) Len() int {
+	return len(c.Transfers) + len(c.Approvals) + len(c.Ownerships) + len(c.Contract)
+}
+
+// tokenEventIDs topic0：Transfer / Approval / OwnershipTransferred
+type tokenEventIDs struct {
+	Transfer             common.Hash
+	Approval             common.Hash
+	OwnershipTransferred common.Hash
+}
+
+var loadTokenEventIDs = sync.OnceValues(func() (tokenEventIDs, error) {
+	parsed, err := erc20.TimeLedgerTokenMetaData.GetAbi()
+	if err != nil {
+		return tokenEventIDs{}, err
+	}
+
+	ids := tokenEventIDs{}
+	for name, dst := range map[string]*common.Hash{
+		"Transfer":             &ids.Transfer,
+		"Approval":             &ids.Approval,
+		"OwnershipTransferred": &ids.OwnershipTransferred,
+	} {
+		ev, ok := parsed.Events[name]
+		if !ok {
+			return tokenEventIDs{}, fmt.Errorf("event %s not found in token abi", name)
+		}
+		*dst = ev.ID
+	}
+	return ids, nil
+})
+
+/*
+====================
+Approval / Ownership Apply
+====================
+*/
+
+// applyApproval 写 approval_log，并用新插入的 Approval 覆盖 token_allowance
+// 事件按 block / log 升序应用，最后一次覆盖即为最新授权
+func applyApproval(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	ev ApprovalEvent,
+) error {
+
+	res := tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.ApprovalLog{
+			ChainID:         chainID,
+			ContractAddress: contract,
+			Owner:           ev.Owner.Hex(),
+			Spender:         ev.Spender.Hex(),
+			Value:           ev.Value.String(),
+			BlockNumber:     int64(ev.BlockNumber),
+			BlockTime:       ev.BlockTime,
+			TxHash:          ev.TxHash.Hex(),
+			LogIndex:        int64(ev.LogIndex),
+			CreatedAt:       time.Now().UTC(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	//	重复事件，直接退出
+	if res.RowsAffected == 0 {
+		return nil
+	}
+
+	return upsertAllowance(tx, models.TokenAllowance{
+		ChainID:         chainID,
+		ContractAddress: contract,
+		Owner:           ev.Owner.Hex(),
+		Spender:         ev.Spender.Hex(),
+		Allowance:       ev.Value.String(),
+		Unlimited:       ev.Value.Cmp(math.MaxBig256) == 0,
+		BlockNumber:     int64(ev.BlockNumber),
+		BlockTime:       ev.BlockTime,
+		TxHash:          ev.TxHash.Hex(),
+		LogIndex:        int64(ev.LogIndex),
+		UpdatedAt:       time.Now().UTC(),
+	})
+}
+
+// upsertAllowance 写入授权快照
+func upsertAllowance(tx *gorm.DB, a models.TokenAllowance) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "chain_id"}, {Name: "contract_address"}, {Name: "owner"}, {Name: "spender"},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"allowance", "unlimited", "block_number", "block_time", "tx_hash", "log_index", "updated_at",
+		}),
+	}).Create(&a).Error
+}
+
+// applyOwnership 写 ownership_history（幂等），并同步 sys_contracts.owner
+func applyOwnership(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	ev OwnershipEvent,
+) error {
+
+	res := tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.OwnershipHistory{
+			ChainID:         chainID,
+			ContractAddress: contract,
+			PreviousOwner:   ev.PreviousOwner.Hex(),
+			NewOwner:        ev.NewOwner.Hex(),
+			BlockNumber:     int64(ev.BlockNumber),
+			BlockTime:       ev.BlockTime,
+			TxHash:          ev.TxHash.Hex(),
+			LogIndex:        int64(ev.LogIndex),
+			CreatedAt:       time.Now().UTC(),
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	return setContractOwner(tx, chainID, contract, ev.NewOwner.Hex())
+}
+
+// setContractOwner 更新合约当前 owner
+func setContractOwner(tx *gorm.DB, chainID int64, contract, owner string) error {
+	return tx.Model(&models.SysContract{}).
+		Where("chain_id=? AND address=?", chainID, contract).
+		Updates(map[string]any{
+			"owner":      owner,
+			"updated_at": time.Now().UTC(),
+		}).Error
+}
+
+// rollbackTokenEvents 回滚 fork 段的 Approval / OwnershipTransferred
+// token_allowance 只重建受影响的 (owner, spender)：取剩余流水中最新的一条，没有则删除
+func rollbackTokenEvents(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	ancestor int64,
+) error {
+
+	type pair struct {
+		Owner   string
+		Spender string
+	}
+
+	var pairs []pair
+	if err := tx.Model(&models.ApprovalLog{}).
+		Distinct("owner", "spender").
+		Where(
+			"chain_id=? AND contract_address=? AND block_number > ?",
+			chainID, contract, ancestor,
+		).
+		Scan(&pairs).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where(
+		"chain_id=? AND contract_address=? AND block_number > ?",
+		chainID, contract, ancestor,
+	).Delete(&models.ApprovalLog{}).Error; err != nil {
+		return err
+	}
+
+	// fork 段最早的一次 owner 变更：回滚后 owner 恢复为它的 previous_owner
+	var firstForked models.OwnershipHistory
+	if err := tx.
+		Where(
+			"chain_id=? AND contract_address=? AND block_number > ?",
+			chainID, contract, ancestor,
+		).
+		Order("block_number ASC, log_index ASC").
+		Limit(1).
+		Find(&firstForked).Error; err != nil {
+		return err
+	}
+
+	if firstForked.ID != 0 {
+		if err := tx.Where(
+			"chain_id=? AND contract_address=? AND block_number > ?",
+			chainID, contract, ancestor,
+		).Delete(&models.OwnershipHistory{}).Error; err != nil {
+			return err
+		}
+
+		if err := setContractOwner(tx, chainID, contract, firstForked.PreviousOwner); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, p := range pairs {
+		var last models.ApprovalLog
+		err := tx.
+			Where(
+				"chain_id=? AND contract_address=? AND owner=? AND spender=?",
+				chainID, contract, p.Owner, p.Spender,
+			).
+			Order("block_number DESC, log_index DESC").
+			Limit(1).
+			Find(&last).Error
+		if err != nil {
+			return err
+		}
+
+		//	fork 前从未授权过，直接删除快照
+		if last.ID == 0 {
+			if err := tx.Where(
+				"chain_id=? AND contract_address=? AND owner=? AND spender=?",
+				chainID, contract, p.Owner, p.Spender,
+			).Delete(&models.TokenAllowance{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := new(big.Int).SetString(last.Value, 10)
+		if !ok {
+			return fmt.Errorf("invalid approval value %q id=%d", last.Value, last.ID)
+		}
+
+		if err := upsertAllowance(tx, models.TokenAllowance{
+			ChainID:         chainID,
+			ContractAddress: contract,
+			Owner:           last.Owner,
+			Spender:         last.Spender,
+			Allowance:       last.Value,
+			Unlimited:       value.Cmp(math.MaxBig256) == 0,
+			BlockNumber:     last.BlockNumber,
+			BlockTime:       last.BlockTime,
+			TxHash:          last.TxHash,
+			LogIndex:        last.LogIndex,
+			UpdatedAt:       now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
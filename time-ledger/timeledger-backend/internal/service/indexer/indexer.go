@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"slices"
 	"sort"
 	"sync"
 	"time"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -22,6 +23,8 @@ import (
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/config"
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
 	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/events"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/lease"
 	erc20 "github.com/Atom257/web3-labs/timeledger-backend/pkg/contract/erc20"
 )
 
@@ -40,9 +43,21 @@ type Indexer struct {
 	// 全局 RPC 限速器
 	rpcLimiter *RPCLimiter
 
+	// 多副本协调：按合约持有租约
+	leases *lease.Keeper
+
 	// 内存中的 scan cursor（key = chainID + contract）
 	scanCache map[string]int64
 	scanMu    sync.Mutex
+
+	// 进程内合约锁（key = chainID:contract）：同步与手动回退互斥
+	contractLocks sync.Map
+
+	// OP Stack pending 区块暂存
+	pending PendingStore
+
+	// cursor 推进通知（可选，供 calculator 事件触发）
+	bus events.Bus
 }
 
 func New(db *gorm.DB, cfg *config.Config, rdb *redis.Client) *Indexer {
@@ -51,10 +66,32 @@ func New(db *gorm.DB, cfg *config.Config, rdb *redis.Client) *Indexer {
 		cfg:        cfg,
 		redis:      rdb,
 		rpcLimiter: NewRPCLimiter(3), // Alchemy 测试账号：3 RPS
+		leases:     lease.NewKeeper(db, cfg.Coordination),
 		scanCache:  make(map[string]int64),
+		pending:    NewPendingStore(db, cfg, rdb),
 	}
 }
 
+// SetEventBus 设置事件总线，cursor 推进后发布 CursorAdvanced（需在运行前调用）
+func (ix *Indexer) SetEventBus(bus events.Bus) {
+	ix.bus = bus
+}
+
+// Shutdown 优雅退出时调用（RunOnceConcurrent 返回之后）：释放本实例持有的合约租约，
+// 其他副本无需等待租约过期即可接管
+func (ix *Indexer) Shutdown(ctx context.Context) {
+	ix.leases.Release(ctx)
+}
+
+// NewPendingStore 配置了 Redis 时暂存到 Redis，否则暂存到 DB
+// API 的 latest 视图需与 indexer 使用同一种存储
+func NewPendingStore(db *gorm.DB, cfg *config.Config, rdb *redis.Client) PendingStore {
+	if rdb != nil {
+		return newRedisPendingStore(rdb, cfg.Redis.KeyPrefix, cfg.Redis.PendingTTLSec)
+	}
+	return newDBPendingStore(db)
+}
+
 /*
 RunOnceConcurrent
 -------
@@ -97,7 +134,14 @@ func (ix *Indexer) RunOnceConcurrent(ctx context.Context) error {
 		contractGroups[c.ChainID] = append(contractGroups[c.ChainID], c)
 	}
 
-	// 5. 并发执行
+	// 5. 读取同步状态，失败退避中的合约本轮跳过
+	statuses, err := ix.loadSyncStatuses(ctx)
+	if err != nil {
+		return fmt.Errorf("load sync status failed: %w", err)
+	}
+	now := time.Now().UTC()
+
+	// 6. 并发执行
 	g, ctx := errgroup.WithContext(ctx)
 
 	for chainID, targets := range contractGroups {
@@ -132,35 +176,123 @@ func (ix *Indexer) RunOnceConcurrent(ctx context.Context) error {
 			}
 			defer client.Close()
 
-			// 链内串行处理合约
-			for _, contract := range targets {
-				// 【关键】这里传的是 models.SysChain 和 models.SysContract
-				if err := ix.syncContract(ctx, client, adapter, sysChain, contract); err != nil {
-					// 遇到限流，中断该链
-					if errors.Is(err, ErrRateLimited) {
-						log.Printf("[indexer.exit] rate limited on chain %d", chainID)
-						return err
-					}
-					// 其他错误，记录日志但不中断其他合约
-					log.Printf("[Indexer] sync failed contract=%s: %v", contract.Address, err)
+			// 链内按 worker pool 并发处理合约，共享 client 与全局 rpcLimiter
+			ordered := orderContractsByLag(targets, statuses)
+
+			workers := sysChain.ContractWorkers
+			if workers <= 0 {
+				workers = 1
+			}
+
+			cg, cctx := errgroup.WithContext(ctx)
+			cg.SetLimit(workers)
+
+			for _, contract := range ordered {
+				contract := contract
+
+				if inBackoff(statuses[syncStatusKey(chainID, contract.Address)], now) {
+					continue
 				}
+
+				cg.Go(func() error {
+					return ix.runContractWorker(cctx, client, adapter, sysChain, contract)
+				})
 			}
-			return nil
+
+			return cg.Wait()
 		})
 	}
 
 	return g.Wait()
 }
 
+// runContractWorker 在 worker pool 中同步单个合约
+// 只有限流会返回 error（中断整条链），其余错误记入 sync_status
+func (ix *Indexer) runContractWorker(
+	ctx context.Context,
+	client *ethclient.Client,
+	adapter ChainAdapter,
+	sysChain models.SysChain,
+	contract models.SysContract,
+) error {
+
+	// 本进程正在手动回退该合约时跳过
+	mu := ix.contractMutex(sysChain.ChainID, contract.Address)
+	if !mu.TryLock() {
+		return nil
+	}
+	defer mu.Unlock()
+
+	// 获取合约租约，由其他副本持有时跳过
+	l, ok, err := ix.leases.Acquire(ctx, lease.IndexerName(sysChain.ChainID, contract.Address))
+	if err != nil {
+		log.Printf("[Indexer] acquire lease failed contract=%s: %v", contract.Address, err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	leaseCtx := lease.WithLease(ctx, l)
+
+	// 【关键】这里传的是 models.SysChain 和 models.SysContract
+	safeBlock, err := ix.syncContract(leaseCtx, client, adapter, sysChain, contract)
+	if err != nil {
+		// 租约被接管：交给新持有者，不计入失败
+		if errors.Is(err, repository.ErrLeaseLost) {
+			log.Printf("[Indexer] lease lost contract=%s, yielding", contract.Address)
+			return nil
+		}
+
+		// 同链其他 worker 触发限流导致的取消，不计入本合约失败
+		if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+			return nil
+		}
+
+		ix.recordSyncFailure(ctx, sysChain, contract, err)
+
+		// 遇到限流，中断该链
+		if errors.Is(err, ErrRateLimited) {
+			log.Printf("[indexer.exit] rate limited on chain %d", sysChain.ChainID)
+			return err
+		}
+		// 其他错误，记录日志但不中断其他合约
+		log.Printf("[Indexer] sync failed contract=%s: %v", contract.Address, err)
+		return nil
+	}
+
+	ix.recordSyncSuccess(ctx, sysChain, contract, safeBlock)
+	return nil
+}
+
+// orderContractsByLag 公平调度：落后少（已追上）的合约优先进入 worker pool，
+// 大回填排在后面，且受 max_chunks_per_run 限制，不会长期占满所有 worker
+func orderContractsByLag(
+	targets []models.SysContract,
+	statuses map[string]models.SyncStatus,
+) []models.SysContract {
+
+	ordered := make([]models.SysContract, len(targets))
+	copy(ordered, targets)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		li := statuses[syncStatusKey(ordered[i].ChainID, ordered[i].Address)].LagBlocks
+		lj := statuses[syncStatusKey(ordered[j].ChainID, ordered[j].Address)].LagBlocks
+		return li < lj
+	})
+
+	return ordered
+}
+
 // syncContract 是 indexer 的核心编排函数
 // 参数已全部修改为 models.SysChain 和 models.SysContract
+// 返回本轮使用的 safe block，用于更新 sync_status
 func (ix *Indexer) syncContract(
 	ctx context.Context,
 	client *ethclient.Client,
 	adapter ChainAdapter,
 	chain models.SysChain,
 	contract models.SysContract,
-) error {
+) (uint64, error) {
 
 	log.Printf(
 		"[ENTER syncContract] chain=%d type=%s contract=%s",
@@ -169,10 +301,16 @@ func (ix *Indexer) syncContract(
 		contract.Address,
 	)
 
+	// 首次同步：读取链上元数据（decimals 等）
+	contract, err := ix.onboardContract(ctx, client, chain, contract)
+	if err != nil {
+		return 0, err
+	}
+
 	// 加载或初始化 cursor
 	cursor, err := ix.loadOrInitCursor(chain.ChainID, contract)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// 确保函数结束时清理内存 scanCache
@@ -191,12 +329,19 @@ func (ix *Indexer) syncContract(
 
 	// 补齐初始化状态下缺失的 block_hash
 	if err := ix.ensureCursorHash(ctx, client, chain, cursor); err != nil {
-		return err
+		return 0, err
 	}
 
 	// 已确认的 canonical block
 	dbBlock := cursor.BlockNumber
 
+	// 本轮有新的 safe 数据落库（含中途失败前已提交的 chunk）时发布通知
+	defer func() {
+		if dbBlock > cursor.BlockNumber {
+			ix.publishCursorAdvanced(ctx, chain.ChainID, contract.Address, dbBlock)
+		}
+	}()
+
 	// 已落库的 scan_block_number（用于控制 flush 间隔）
 	scanFlushed := cursor.ScanBlockNumber
 
@@ -215,14 +360,14 @@ func (ix *Indexer) syncContract(
 			contract.Address,
 			int64(chain.ReorgWindow),
 		); err != nil {
-			return err
+			return 0, err
 		}
 	}
 
 	// 当前 safe block (Adapter 已适配 models.SysChain)
 	safeBlock, err := adapter.SafeBlock(ctx, client, chain)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	log.Printf(
@@ -235,7 +380,7 @@ func (ix *Indexer) syncContract(
 
 	// 没有可扫描区间
 	if uint64(cursor.BlockNumber) >= safeBlock {
-		return nil
+		return safeBlock, nil
 	}
 
 	// ERC20 合约实例
@@ -244,14 +389,41 @@ func (ix *Indexer) syncContract(
 		client,
 	)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	// 通用事件配置
+	spec, err := parseEventSpec(contract)
+	if err != nil {
+		return 0, err
 	}
 
 	// 计算扫描区间
 	from, to := ix.computeScanRange(cursor, safeBlock)
 
+	// 公平性：单轮 chunk 预算用完即让出 worker，剩余区间下一轮继续
+	chunks := 0
+
 	for start := from; start <= to; {
 
+		if chain.MaxChunksPerRun > 0 && chunks >= chain.MaxChunksPerRun {
+			log.Printf(
+				"[indexer.yield] chain_id=%d contract=%s next=%d target=%d chunks=%d",
+				chain.ChainID,
+				contract.Address,
+				start,
+				to,
+				chunks,
+			)
+			break
+		}
+		chunks++
+
+		// 长时间追块时续约，租约丢失则立即退出
+		if err := ix.leases.Renew(ctx); err != nil {
+			return 0, err
+		}
+
 		end := ix.computeChunkEnd(start, to, chain)
 
 		// RPC 限速
@@ -259,18 +431,20 @@ func (ix *Indexer) syncContract(
 			time.Sleep(time.Duration(chain.RequestDelayMs) * time.Millisecond)
 		}
 
-		// 拉取 Transfer 事件
-		events, headers, err := ix.fetchTransfers(
+		// 拉取 Transfer / Approval / OwnershipTransferred 及通用事件
+		events, headers, err := ix.fetchEvents(
 			ctx,
 			client,
 			token,
+			spec,
+			contract.TokenStandard,
 			chain.ChainID,
 			contract.Address,
 			start,
 			end,
 		)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
 		// 更新内存 scan 进度
@@ -278,11 +452,11 @@ func (ix *Indexer) syncContract(
 
 		// 周期性落库 scan_block_number
 		if err := ix.flushScanCursor(ctx, client, chain, contract, &scanFlushed); err != nil {
-			return err
+			return 0, err
 		}
 
-		if chain.Type == "opstack" && ix.redis != nil {
-			// OP Stack：写 Redis pending
+		if chain.Type == "opstack" {
+			// OP Stack：写 pending
 			if err := ix.handleOpStackChunk(
 				ctx,
 				client,
@@ -293,7 +467,7 @@ func (ix *Indexer) syncContract(
 				events,
 				&dbBlock,
 			); err != nil {
-				return err
+				return 0, err
 			}
 		} else {
 			// 非 OP Stack：直接落库
@@ -308,7 +482,7 @@ func (ix *Indexer) syncContract(
 				headers,
 				&dbBlock,
 			); err != nil {
-				return err
+				return 0, err
 			}
 		}
 
@@ -317,11 +491,11 @@ func (ix *Indexer) syncContract(
 
 	// 扫描结束后兜底刷新 scan cursor
 	if err := ix.flushScanCursor(ctx, client, chain, contract, &scanFlushed); err != nil {
-		return err
+		return 0, err
 	}
 
 	// OP Stack：兜底 flush safe pending
-	if chain.Type == "opstack" && ix.redis != nil {
+	if chain.Type == "opstack" {
 		if err := ix.FlushSafePending(
 			ctx,
 			client,
@@ -330,11 +504,11 @@ func (ix *Indexer) syncContract(
 			contract,
 			&dbBlock,
 		); err != nil {
-			return err
+			return 0, err
 		}
 	}
 
-	return nil
+	return safeBlock, nil
 }
 
 // handleOpStackChunk 处理 OP Stack 扫描到的一个 chunk
@@ -345,7 +519,7 @@ func (ix *Indexer) handleOpStackChunk(
 	chain models.SysChain,
 	contract models.SysContract,
 	headers map[uint64]*blockHeaderMini,
-	events []TransferEvent,
+	events ChunkEvents,
 	dbBlock *int64,
 ) error {
 
@@ -361,12 +535,7 @@ func (ix *Indexer) handleOpStackChunk(
 	for _, bn := range blockNums {
 		h := headers[bn]
 
-		var blockEvents []TransferEvent
-		for _, ev := range events {
-			if ev.BlockNumber == h.Number {
-				blockEvents = append(blockEvents, ev)
-			}
-		}
+		blockEvents := events.ForBlock(h.Number)
 
 		pb := &PendingBlock{
 			ChainID:         chain.ChainID,
@@ -375,11 +544,14 @@ func (ix *Indexer) handleOpStackChunk(
 			BlockHash:       h.Hash.Hex(),
 			ParentHash:      h.Parent.Hex(),
 			BlockTime:       h.Time,
-			Events:          blockEvents,
+			Events:          blockEvents.Transfers,
+			Approvals:       blockEvents.Approvals,
+			Ownerships:      blockEvents.Ownerships,
+			ContractEvents:  blockEvents.Contract,
 			CreatedAt:       time.Now().UTC(),
 		}
 
-		if err := ix.StagePendingBlock(ctx, ix.redis, pb); err != nil {
+		if err := ix.pending.Stage(ctx, pb); err != nil {
 			return err
 		}
 	}
@@ -411,28 +583,24 @@ func (ix *Indexer) FlushSafePending(
 		return err
 	}
 
-	// 2. 从 Redis 读取 pending blocks
-	pendingBlocks, err := ix.ListPendingBlocksUpTo(
+	// 2. 读取 pending blocks
+	pendingBlocks, err := ix.pending.ListUpTo(
 		ctx,
-		ix.redis,
 		chain.ChainID,
 		contract.Address,
+		*dbBlock,
 		safeBlock,
 	)
 	if err != nil {
 		return err
 	}
 
-	if len(pendingBlocks) == 0 {
-		return nil
-	}
-
-	sort.Slice(pendingBlocks, func(i, j int) bool {
-		return pendingBlocks[i].BlockNumber < pendingBlocks[j].BlockNumber
-	})
-
 	for _, pb := range pendingBlocks {
 		if int64(pb.BlockNumber) <= *dbBlock {
+			// 已落库（上次删除未完成）
+			if err := ix.pending.Delete(ctx, chain.ChainID, contract.Address, pb.BlockNumber); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -450,7 +618,7 @@ func (ix *Indexer) FlushSafePending(
 			chain.ChainID,
 			contract.Address,
 			pb.BlockNumber,
-			len(pb.Events),
+			pb.chunkEvents().Len(),
 			safeBlock,
 		)
 
@@ -459,10 +627,10 @@ func (ix *Indexer) FlushSafePending(
 			ctx,
 			client,
 			chain.ChainID,
-			contract.Address,
+			contract,
 			pb.BlockNumber,
 			pb.BlockNumber,
-			pb.Events,
+			pb.chunkEvents(),
 			headers,
 		); err != nil {
 			return err
@@ -470,15 +638,10 @@ func (ix *Indexer) FlushSafePending(
 
 		*dbBlock = int64(pb.BlockNumber)
 
-		// 4. 清理 Redis
-		key := fmt.Sprintf(
-			"%s:pending:block:%d:%s:%d",
-			ix.cfg.Redis.KeyPrefix,
-			chain.ChainID,
-			contract.Address,
-			pb.BlockNumber,
-		)
-		_ = ix.redis.Del(ctx, key).Err()
+		// 4. 清理 pending（失败也不影响正确性：下次按 dbBlock 跳过并重试删除）
+		if err := ix.pending.Delete(ctx, chain.ChainID, contract.Address, pb.BlockNumber); err != nil {
+			log.Printf("[opstack.pending] delete failed contract=%s block=%d: %v", contract.Address, pb.BlockNumber, err)
+		}
 	}
 
 	return nil
@@ -491,7 +654,7 @@ func (ix *Indexer) applyNormalChunk(
 	chain models.SysChain,
 	contract models.SysContract,
 	start, end uint64,
-	events []TransferEvent,
+	events ChunkEvents,
 	headers map[uint64]*blockHeaderMini,
 	dbBlock *int64,
 ) error {
@@ -500,7 +663,7 @@ func (ix *Indexer) applyNormalChunk(
 		ctx,
 		client,
 		chain.ChainID,
-		contract.Address,
+		contract,
 		start,
 		end,
 		events,
@@ -671,7 +834,7 @@ func (ix *Indexer) loadOrInitCursor(
 
 /*
 ====================
-Transfer Fetch
+Event Fetch
 ====================
 */
 
@@ -683,6 +846,10 @@ type TransferEvent struct {
 	To          common.Address
 	Value       *big.Int
 	BlockTime   time.Time
+
+	// NFT：tokenId 及对应数量（ERC20 为空），Value 为数量之和
+	TokenIDs     []*big.Int `json:",omitempty"`
+	TokenAmounts []*big.Int `json:",omitempty"`
 }
 
 type blockHeaderMini struct {
@@ -692,31 +859,61 @@ type blockHeaderMini struct {
 	Time   time.Time
 }
 
-func (ix *Indexer) fetchTransfers(
+// fetchEvents 拉取 [start, end] 内的 Transfer / Approval / OwnershipTransferred 及通用事件
+// 一次 eth_getLogs 按 topic0 同时匹配，内置事件用 binding 的 ParseXxx 解析，通用事件按 ABI 解码
+func (ix *Indexer) fetchEvents(
 	ctx context.Context,
 	client *ethclient.Client,
 	token *erc20.TimeLedgerToken,
+	spec *eventSpec,
+	standard string,
 	chainID int64,
 	contract string,
 	start, end uint64,
-) ([]TransferEvent, map[uint64]*blockHeaderMini, error) {
+) (ChunkEvents, map[uint64]*blockHeaderMini, error) {
+
+	ids, err := loadTokenEventIDs()
+	if err != nil {
+		return ChunkEvents{}, nil, err
+	}
+
+	// ERC-1155 的转账事件
+	erc1155, err := loadERC1155ABI()
+	if err != nil {
+		return ChunkEvents{}, nil, err
+	}
+	transferSingle := erc1155.Events["TransferSingle"]
+	transferBatch := erc1155.Events["TransferBatch"]
+
+	// topic0 去重（通用事件可能与内置事件重叠，如同时配置了 Transfer）
+	topic0 := []common.Hash{ids.Transfer, ids.Approval, ids.OwnershipTransferred}
+	if standard == models.TokenStandardERC1155 {
+		topic0 = append(topic0, transferSingle.ID, transferBatch.ID)
+	}
+	for _, id := range spec.topics() {
+		if !slices.Contains(topic0, id) {
+			topic0 = append(topic0, id)
+		}
+	}
 
 	// eth_getLogs（带 limiter + retry）
-	it, err := callRPCWithRetry(
+	logs, err := callRPCWithRetry(
 		ctx,
 		ix.rpcLimiter,
 		"eth_getLogs",
 		chainID,
 		start,
-		func() (*erc20.TimeLedgerTokenTransferIterator, error) {
-			return token.FilterTransfer(&bind.FilterOpts{
-				Start: start,
-				End:   &end,
-			}, nil, nil)
+		func() ([]types.Log, error) {
+			return client.FilterLogs(ctx, ethereum.FilterQuery{
+				FromBlock: new(big.Int).SetUint64(start),
+				ToBlock:   new(big.Int).SetUint64(end),
+				Addresses: []common.Address{common.HexToAddress(contract)},
+				Topics:    [][]common.Hash{topic0},
+			})
 		},
 	)
 	if err != nil {
-		return nil, nil, err
+		return ChunkEvents{}, nil, err
 	}
 
 	headers := make(map[uint64]*blockHeaderMini)
@@ -750,35 +947,131 @@ func (ix *Indexer) fetchTransfers(
 		return bh, nil
 	}
 
-	var events []TransferEvent
+	var events ChunkEvents
 
-	for it.Next() {
-		ev := it.Event
-		if ev.Raw.Removed {
+	for _, lg := range logs {
+		if lg.Removed || len(lg.Topics) == 0 {
 			continue
 		}
 
-		h, err := getHeader(ev.Raw.BlockNumber)
+		h, err := getHeader(lg.BlockNumber)
 		if err != nil {
-			return nil, nil, err
+			return ChunkEvents{}, nil, err
 		}
 
-		events = append(events, TransferEvent{
-			BlockNumber: ev.Raw.BlockNumber,
-			LogIndex:    ev.Raw.Index,
-			TxHash:      ev.Raw.TxHash,
-			From:        ev.From,
-			To:          ev.To,
-			Value:       ev.Value,
-			BlockTime:   h.Time,
-		})
+		switch lg.Topics[0] {
+		case ids.Transfer:
+			switch standard {
+			case models.TokenStandardERC721:
+				ev, err := parseERC721Transfer(lg, h.Time)
+				if err != nil {
+					return ChunkEvents{}, nil, fmt.Errorf("parse Transfer block=%d log=%d: %w", lg.BlockNumber, lg.Index, err)
+				}
+				events.Transfers = append(events.Transfers, ev)
+
+			case models.TokenStandardERC1155:
+				// ERC-1155 不使用 Transfer
+
+			default:
+				ev, err := token.ParseTransfer(lg)
+				if err != nil {
+					return ChunkEvents{}, nil, fmt.Errorf("parse Transfer block=%d log=%d: %w", lg.BlockNumber, lg.Index, err)
+				}
+				events.Transfers = append(events.Transfers, TransferEvent{
+					BlockNumber: lg.BlockNumber,
+					LogIndex:    lg.Index,
+					TxHash:      lg.TxHash,
+					From:        ev.From,
+					To:          ev.To,
+					Value:       ev.Value,
+					BlockTime:   h.Time,
+				})
+			}
+
+		case transferSingle.ID, transferBatch.ID:
+			if standard != models.TokenStandardERC1155 {
+				break
+			}
+			abiEv := transferSingle
+			if lg.Topics[0] == transferBatch.ID {
+				abiEv = transferBatch
+			}
+			ev, err := parseERC1155Transfer(abiEv, lg, h.Time)
+			if err != nil {
+				return ChunkEvents{}, nil, fmt.Errorf("parse %s block=%d log=%d: %w", abiEv.Name, lg.BlockNumber, lg.Index, err)
+			}
+			events.Transfers = append(events.Transfers, ev)
+
+		case ids.Approval:
+			// ERC-721 Approval(owner, approved, tokenId) 是单个 token 的授权，不计入额度授权
+			if len(lg.Topics) != 3 {
+				break
+			}
+			ev, err := token.ParseApproval(lg)
+			if err != nil {
+				return ChunkEvents{}, nil, fmt.Errorf("parse Approval block=%d log=%d: %w", lg.BlockNumber, lg.Index, err)
+			}
+			events.Approvals = append(events.Approvals, ApprovalEvent{
+				BlockNumber: lg.BlockNumber,
+				LogIndex:    lg.Index,
+				TxHash:      lg.TxHash,
+				Owner:       ev.Owner,
+				Spender:     ev.Spender,
+				Value:       ev.Value,
+				BlockTime:   h.Time,
+			})
+
+		case ids.OwnershipTransferred:
+			ev, err := token.ParseOwnershipTransferred(lg)
+			if err != nil {
+				return ChunkEvents{}, nil, fmt.Errorf("parse OwnershipTransferred block=%d log=%d: %w", lg.BlockNumber, lg.Index, err)
+			}
+			events.Ownerships = append(events.Ownerships, OwnershipEvent{
+				BlockNumber:   lg.BlockNumber,
+				LogIndex:      lg.Index,
+				TxHash:        lg.TxHash,
+				PreviousOwner: ev.PreviousOwner,
+				NewOwner:      ev.NewOwner,
+				BlockTime:     h.Time,
+			})
+		}
+
+		if abiEv, ok := spec.lookup(lg.Topics[0]); ok {
+			ev, err := decodeContractLog(abiEv, lg, h.Time)
+			if err != nil {
+				return ChunkEvents{}, nil, fmt.Errorf("decode block=%d log=%d: %w", lg.BlockNumber, lg.Index, err)
+			}
+			events.Contract = append(events.Contract, ev)
+		}
 	}
 
-	sort.Slice(events, func(i, j int) bool {
-		if events[i].BlockNumber != events[j].BlockNumber {
-			return events[i].BlockNumber < events[j].BlockNumber
+	sort.Slice(events.Transfers, func(i, j int) bool {
+		a, b := events.Transfers[i], events.Transfers[j]
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
 		}
-		return events[i].LogIndex < events[j].LogIndex
+		return a.LogIndex < b.LogIndex
+	})
+	sort.Slice(events.Approvals, func(i, j int) bool {
+		a, b := events.Approvals[i], events.Approvals[j]
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
+		}
+		return a.LogIndex < b.LogIndex
+	})
+	sort.Slice(events.Ownerships, func(i, j int) bool {
+		a, b := events.Ownerships[i], events.Ownerships[j]
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
+		}
+		return a.LogIndex < b.LogIndex
+	})
+	sort.Slice(events.Contract, func(i, j int) bool {
+		a, b := events.Contract[i], events.Contract[j]
+		if a.BlockNumber != b.BlockNumber {
+			return a.BlockNumber < b.BlockNumber
+		}
+		return a.LogIndex < b.LogIndex
 	})
 
 	return events, headers, nil
@@ -792,17 +1085,71 @@ Apply Chunk
 
 var zeroAddr = common.HexToAddress("0x0000000000000000000000000000000000000000")
 
+// transferKind 按零地址区分 mint / burn / transfer
+func transferKind(ev TransferEvent) string {
+	switch {
+	case ev.From == zeroAddr && ev.To != zeroAddr:
+		return models.TransferKindMint
+	case ev.To == zeroAddr && ev.From != zeroAddr:
+		return models.TransferKindBurn
+	default:
+		return models.TransferKindTransfer
+	}
+}
+
 func (ix *Indexer) applyChunkTx(
 	ctx context.Context,
 	client *ethclient.Client,
 	chainID int64,
-	contract string,
+	sysContract models.SysContract,
 	start, end uint64,
-	events []TransferEvent,
+	events ChunkEvents,
 	headers map[uint64]*blockHeaderMini,
 ) error {
 
-	return ix.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	contract := sysContract.Address
+
+	//	chunk 末尾 block header 兜底（用于 cursor）
+	endHeader := headers[end]
+	if endHeader == nil {
+		h, err := callRPCWithRetry(
+			ctx,
+			ix.rpcLimiter,
+			"eth_getBlockByNumber",
+			chainID,
+			end,
+			func() (*types.Header, error) {
+				return client.HeaderByNumber(ctx, big.NewInt(int64(end)))
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		endHeader = &blockHeaderMini{
+			Number: end,
+			Hash:   h.Hash(),
+			Parent: h.ParentHash,
+			Time:   time.Unix(int64(h.Time), 0).UTC(),
+		}
+	}
+
+	// snapshot 模式：事务前拉取链上余额（RPC 不放在 DB 事务内）
+	var snaps *chunkSnapshots
+	if isSnapshotMode(sysContract) {
+		var err error
+		snaps, err = ix.fetchSnapshots(ctx, client, chainID, sysContract, end, events.Transfers)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := ix.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+
+		//	fencing：租约已易主则拒绝写入
+		if err := ix.leases.CheckFence(ctx, tx); err != nil {
+			return err
+		}
 
 		//	写 block_header（幂等 + hash 一致性校验）
 		for _, h := range headers {
@@ -844,46 +1191,98 @@ func (ix *Indexer) applyChunkTx(
 			}
 		}
 
-		//	应用 Transfer 事件
-		for _, ev := range events {
-			if ev.From != zeroAddr {
-				if err := ix.applyAccountDelta(
-					tx, chainID, contract, ev, ev.From, new(big.Int).Neg(ev.Value),
-				); err != nil {
-					return err
+		//	应用 Transfer 事件（mint / burn 按区块汇总到 token_supply）
+		supply := newSupplyTracker(tx, chainID, contract)
+		if err := supply.applyTransfers(events.Transfers, func(ev TransferEvent) error {
+			//	原始事件流水（幂等）
+			if err := tx.
+				Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.TransferEvent{
+					ChainID:         chainID,
+					ContractAddress: contract,
+					BlockNumber:     int64(ev.BlockNumber),
+					BlockTime:       ev.BlockTime,
+					TxHash:          ev.TxHash.Hex(),
+					LogIndex:        int64(ev.LogIndex),
+					FromAddress:     ev.From.Hex(),
+					ToAddress:       ev.To.Hex(),
+					Value:           ev.Value.String(),
+					Kind:            transferKind(ev),
+					TokenIDs:        joinBigInts(ev.TokenIDs),
+					TokenAmounts:    joinBigInts(ev.TokenAmounts),
+					CreatedAt:       time.Now().UTC(),
+				}).Error; err != nil {
+				return err
+			}
+
+			// 发送方余额变动被 skip 策略跳过
+			skipFrom := false
+
+			if snaps != nil {
+				//	snapshot 模式：Transfer 只标记账户，余额取链上观测值
+				for _, acct := range []common.Address{ev.From, ev.To} {
+					if acct == zeroAddr {
+						continue
+					}
+					if err := ix.applyAccountSnapshot(tx, chainID, contract, snaps, ev, acct); err != nil {
+						return err
+					}
+				}
+			} else {
+				if ev.From != zeroAddr {
+					skipped, err := ix.applyAccountDelta(
+						ctx, tx, chainID, sysContract, ev, ev.From, new(big.Int).Neg(ev.Value),
+					)
+					if err != nil {
+						return err
+					}
+					skipFrom = skipped
+				}
+				if ev.To != zeroAddr {
+					if _, err := ix.applyAccountDelta(
+						ctx, tx, chainID, sysContract, ev, ev.To, ev.Value,
+					); err != nil {
+						return err
+					}
 				}
 			}
-			if ev.To != zeroAddr {
-				if err := ix.applyAccountDelta(
-					tx, chainID, contract, ev, ev.To, ev.Value,
-				); err != nil {
+
+			//	NFT：tokenId 维度持仓（与账户总数使用同一异常决策：总数被 skip 时持仓也不扣减）
+			if len(ev.TokenIDs) > 0 {
+				if err := applyTokenHoldings(ctx, tx, chainID, sysContract, ev, skipFrom); err != nil {
 					return err
 				}
 			}
+			return nil
+		}); err != nil {
+			return err
 		}
 
-		//	chunk 末尾 block header 兜底（用于 cursor）
-		endHeader := headers[end]
-		if endHeader == nil {
-			h, err := callRPCWithRetry(
-				ctx,
-				ix.rpcLimiter,
-				"eth_getBlockByNumber",
-				chainID,
-				end,
-				func() (*types.Header, error) {
-					return client.HeaderByNumber(ctx, big.NewInt(int64(end)))
-				},
-			)
-			if err != nil {
+		//	snapshot 模式全量核对（rebase）
+		if snaps != nil && snaps.sweepBlock > 0 {
+			if err := ix.applyRebaseSweep(tx, chainID, contract, snaps, endHeader.Time); err != nil {
+				return err
+			}
+		}
+
+		//	应用 Approval 事件（授权流水 + 授权快照）
+		for _, ev := range events.Approvals {
+			if err := applyApproval(tx, chainID, contract, ev); err != nil {
+				return err
+			}
+		}
+
+		//	应用 OwnershipTransferred 事件
+		for _, ev := range events.Ownerships {
+			if err := applyOwnership(tx, chainID, contract, ev); err != nil {
 				return err
 			}
+		}
 
-			endHeader = &blockHeaderMini{
-				Number: end,
-				Hash:   h.Hash(),
-				Parent: h.ParentHash,
-				Time:   time.Unix(int64(h.Time), 0).UTC(),
+		//	应用通用事件
+		for _, ev := range events.Contract {
+			if err := applyContractEvent(tx, chainID, contract, ev); err != nil {
+				return err
 			}
 		}
 
@@ -897,6 +1296,13 @@ func (ix *Indexer) applyChunkTx(
 				"updated_at":      time.Now().UTC(),
 			}).Error
 	})
+
+	// halt 策略：事务已回滚，异常在事务外补记，便于排查
+	if err != nil {
+		ix.recordHaltedAnomaly(ctx, err)
+	}
+
+	return err
 }
 
 /*
@@ -905,14 +1311,65 @@ Balance
 ====================
 */
 
+// applyAccountDelta 应用账户余额变动，返回是否按 skip 策略跳过了本次变动
 func (ix *Indexer) applyAccountDelta(
+	ctx context.Context,
 	tx *gorm.DB,
 	chainID int64,
-	contract string,
+	sysContract models.SysContract,
 	ev TransferEvent,
 	account common.Address,
 	delta *big.Int,
-) error {
+) (bool, error) {
+
+	contract := sysContract.Address
+
+	ub, err := lockUserBalance(tx, chainID, contract, account)
+	if err != nil {
+		return false, err
+	}
+
+	//	计算新余额
+	before, _ := new(big.Int).SetString(ub.Balance, 10)
+	cur := new(big.Int).Add(before, delta)
+
+	//	负数保护（必须）：按合约策略隔离异常
+	var anomaly *models.IndexerAnomaly
+	if cur.Sign() < 0 {
+		a := newNegativeBalanceAnomaly(chainID, sysContract, ev, account, before, delta, cur)
+
+		switch a.Policy {
+		case models.AnomalyPolicySkip:
+			// 跳过该账户的本次变动，余额保持不变
+			return true, recordAnomaly(ctx, tx, &a)
+		case models.AnomalyPolicyClamp:
+			// 截断为 0，balance_log 记录实际生效的 delta
+			cur = big.NewInt(0)
+			delta = new(big.Int).Neg(before)
+			anomaly = &a
+		default:
+			return false, &AnomalyError{Anomaly: a}
+		}
+	}
+
+	inserted, err := writeBalanceChange(tx, chainID, contract, &ub, ev, account, delta, cur)
+	if err != nil || !inserted {
+		return false, err
+	}
+
+	if anomaly != nil {
+		return false, recordAnomaly(ctx, tx, anomaly)
+	}
+	return false, nil
+}
+
+// lockUserBalance 锁定并读取账户余额，不存在时返回余额为 0 的新记录（未落库）
+func lockUserBalance(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	account common.Address,
+) (models.UserBalance, error) {
 
 	var ub models.UserBalance
 	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
@@ -923,27 +1380,27 @@ func (ix *Indexer) applyAccountDelta(
 		First(&ub).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		ub = models.UserBalance{
+		return models.UserBalance{
 			ChainID:         chainID,
 			ContractAddress: contract,
 			Account:         account.Hex(),
 			Balance:         "0",
-		}
-	} else if err != nil {
-		return err
+		}, nil
 	}
+	return ub, err
+}
 
-	//	计算新余额
-	cur, _ := new(big.Int).SetString(ub.Balance, 10)
-	cur.Add(cur, delta)
-
-	//	负数保护（必须）
-	if cur.Sign() < 0 {
-		return fmt.Errorf(
-			"negative balance: acct=%s bal=%s delta=%s",
-			account.Hex(), ub.Balance, delta.String(),
-		)
-	}
+// writeBalanceChange 写 balance_log（幂等），仅在真正插入后同步 user_balance
+// 返回值表示本次是否写入（false = 重复事件）
+func writeBalanceChange(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	ub *models.UserBalance,
+	ev TransferEvent,
+	account common.Address,
+	delta, cur *big.Int,
+) (bool, error) {
 
 	//	写 balance_log（幂等）
 	res := tx.
@@ -962,12 +1419,12 @@ func (ix *Indexer) applyAccountDelta(
 		})
 
 	if res.Error != nil {
-		return res.Error
+		return false, res.Error
 	}
 
 	//	如果这条 log 已存在（重复事件），直接退出
 	if res.RowsAffected == 0 {
-		return nil
+		return false, nil
 	}
 
 	//	仅在 log 真正插入成功后，更新 user_balance
@@ -977,10 +1434,10 @@ func (ix *Indexer) applyAccountDelta(
 	ub.UpdatedAt = time.Now().UTC()
 
 	if ub.ID == 0 {
-		return tx.Create(&ub).Error
+		return true, tx.Create(ub).Error
 	}
 
-	return tx.Model(&models.UserBalance{}).
+	return true, tx.Model(&models.UserBalance{}).
 		Where("id=?", ub.ID).
 		Updates(map[string]any{
 			"balance":      ub.Balance,
@@ -0,0 +1,35 @@
+package indexer
+
+import (
+	"expvar"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Metrics
+-------
+- 通过 expvar 暴露，API 层挂载在 /admin/debug/vars（需 admin token）
+- indexer_reorgs：reorg 次数，key 为 chain:contract，另有 total
+- indexer_reorg_depth_max：观察到的最大 reorg 深度，key 同上
+*/
+
+var (
+	reorgCount    = expvar.NewMap("indexer_reorgs")
+	reorgDepthMax = expvar.NewMap("indexer_reorg_depth_max")
+)
+
+// observeReorg 记录一次已提交的 reorg
+func observeReorg(r *models.ReorgEvent) {
+	key := syncStatusKey(r.ChainID, r.ContractAddress)
+
+	reorgCount.Add(key, 1)
+	reorgCount.Add("total", 1)
+
+	if v, ok := reorgDepthMax.Get(key).(*expvar.Int); ok && v.Value() >= r.Depth {
+		return
+	}
+	d := new(expvar.Int)
+	d.Set(r.Depth)
+	reorgDepthMax.Set(key, d)
+}
@@ -0,0 +1,370 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+NFT
+---
+- ERC-721 Transfer(from, to, tokenId)：与 ERC20 同 topic0，但 tokenId 为 indexed（4 个 topic，data 为空）
+- ERC-1155 TransferSingle / TransferBatch：解析后同样归一为 TransferEvent
+- TransferEvent.Value 为本次转移的数量之和，沿用 ERC20 的 balance_log / user_balance 链路，
+  积分即按“持有数量”计；tokenId 维度的持仓另记 token_holding / token_holding_log
+*/
+
+const erc1155EventsABI = `[
+	{"anonymous":false,"name":"TransferSingle","type":"event","inputs":[
+		{"indexed":true,"name":"operator","type":"address"},
+		{"indexed":true,"name":"from","type":"address"},
+		{"indexed":true,"name":"to","type":"address"},
+		{"indexed":false,"name":"id","type":"uint256"},
+		{"indexed":false,"name":"value","type":"uint256"}]},
+	{"anonymous":false,"name":"TransferBatch","type":"event","inputs":[
+		{"indexed":true,"name":"operator","type":"address"},
+		{"indexed":true,"name":"from","type":"address"},
+		{"indexed":true,"name":"to","type":"address"},
+		{"indexed":false,"name":"ids","type":"uint256[]"},
+		{"indexed":false,"name":"values","type":"uint256[]"}]}
+]`
+
+var loadERC1155ABI = sync.OnceValues(func() (abi.ABI, error) {
+	return abi.JSON(strings.NewReader(erc1155EventsABI))
+})
+
+// parseERC721Transfer 解析 ERC-721 Transfer
+func parseERC721Transfer(lg types.Log, blockTime time.Time) (TransferEvent, error) {
+	if len(lg.Topics) != 4 {
+		return TransferEvent{}, fmt.Errorf("erc721 Transfer expects 4 topics, got %d", len(lg.Topics))
+	}
+
+	return TransferEvent{
+		BlockNumber:  lg.BlockNumber,
+		LogIndex:     lg.Index,
+		TxHash:       lg.TxHash,
+		From:         common.BytesToAddress(lg.Topics[1].Bytes()),
+		To:           common.BytesToAddress(lg.Topics[2].Bytes()),
+		Value:        big.NewInt(1),
+		TokenIDs:     []*big.Int{lg.Topics[3].Big()},
+		TokenAmounts: []*big.Int{big.NewInt(1)},
+		BlockTime:    blockTime,
+	}, nil
+}
+
+// parseERC1155Transfer 解析 TransferSingle / TransferBatch
+// 同一批次内重复的 tokenId 合并数量，Value 为数量之和
+func parseERC1155Transfer(ev abi.Event, lg types.Log, blockTime time.Time) (TransferEvent, error) {
+	if len(lg.Topics) != 4 {
+		return TransferEvent{}, fmt.Errorf("erc1155 %s expects 4 topics, got %d", ev.Name, len(lg.Topics))
+	}
+
+	vals, err := ev.Inputs.Unpack(lg.Data)
+	if err != nil {
+		return TransferEvent{}, fmt.Errorf("unpack %s: %w", ev.Name, err)
+	}
+
+	var ids, amounts []*big.Int
+	switch ev.Name {
+	case "TransferSingle":
+		ids = []*big.Int{vals[0].(*big.Int)}
+		amounts = []*big.Int{vals[1].(*big.Int)}
+	case "TransferBatch":
+		ids = vals[0].([]*big.Int)
+		amounts = vals[1].([]*big.Int)
+		if len(ids) != len(amounts) {
+			return TransferEvent{}, fmt.Errorf("TransferBatch ids/values length mismatch %d != %d", len(ids), len(amounts))
+		}
+	default:
+		return TransferEvent{}, fmt.Errorf("unexpected erc1155 event %s", ev.Name)
+	}
+
+	// 合并重复 tokenId（保持首次出现的顺序）
+	var (
+		outIDs     []*big.Int
+		outAmounts []*big.Int
+		pos        = make(map[string]int)
+		total      = new(big.Int)
+	)
+	for i, id := range ids {
+		total.Add(total, amounts[i])
+		if p, ok := pos[id.String()]; ok {
+			outAmounts[p] = new(big.Int).Add(outAmounts[p], amounts[i])
+			continue
+		}
+		pos[id.String()] = len(outIDs)
+		outIDs = append(outIDs, id)
+		outAmounts = append(outAmounts, new(big.Int).Set(amounts[i]))
+	}
+
+	return TransferEvent{
+		BlockNumber:  lg.BlockNumber,
+		LogIndex:     lg.Index,
+		TxHash:       lg.TxHash,
+		From:         common.BytesToAddress(lg.Topics[2].Bytes()),
+		To:           common.BytesToAddress(lg.Topics[3].Bytes()),
+		Value:        total,
+		TokenIDs:     outIDs,
+		TokenAmounts: outAmounts,
+		BlockTime:    blockTime,
+	}, nil
+}
+
+// joinBigInts 逗号分隔的十进制串（写 transfer_event）
+func joinBigInts(xs []*big.Int) string {
+	parts := make([]string, len(xs))
+	for i, x := range xs {
+		parts[i] = x.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+/*
+====================
+Holding Apply
+====================
+*/
+
+// applyTokenHoldings 按 tokenId 更新持仓（仅 NFT 事件）
+// skipFrom：发送方的总数变动已按 skip 策略跳过，持仓同样不扣减；
+// tokenId 持仓为负时按合约的异常策略处理（见 applyHoldingDelta）
+func applyTokenHoldings(
+	ctx context.Context,
+	tx *gorm.DB,
+	chainID int64,
+	contract models.SysContract,
+	ev TransferEvent,
+	skipFrom bool,
+) error {
+
+	// 自转账持仓不变
+	if ev.From == ev.To {
+		return nil
+	}
+
+	for i, id := range ev.TokenIDs {
+		amt := ev.TokenAmounts[i]
+		if amt.Sign() == 0 {
+			continue
+		}
+
+		if ev.From != zeroAddr && !skipFrom {
+			if err := applyHoldingDelta(ctx, tx, chainID, contract, ev, ev.From, id, new(big.Int).Neg(amt)); err != nil {
+				return err
+			}
+		}
+		if ev.To != zeroAddr {
+			if err := applyHoldingDelta(ctx, tx, chainID, contract, ev, ev.To, id, amt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyHoldingDelta 应用单个 tokenId 的持仓变动
+// 持仓为负时与账户余额相同：halt 中断 chunk，clamp 截断为 0，skip 保持不变，均记录 indexer_anomaly
+func applyHoldingDelta(
+	ctx context.Context,
+	tx *gorm.DB,
+	chainID int64,
+	sysContract models.SysContract,
+	ev TransferEvent,
+	account common.Address,
+	tokenID *big.Int,
+	delta *big.Int,
+) error {
+
+	contract := sysContract.Address
+	acct := account.Hex()
+	id := tokenID.String()
+
+	//	幂等：同一事件对同一 (account, tokenId) 只应用一次
+	var exists int64
+	if err := tx.Model(&models.TokenHoldingLog{}).
+		Where(
+			"chain_id=? AND contract_address=? AND account=? AND token_id=? AND block_number=? AND log_index=?",
+			chainID, contract, acct, id, ev.BlockNumber, ev.LogIndex,
+		).
+		Count(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	var h models.TokenHolding
+	err := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(
+			"chain_id=? AND contract_address=? AND account=? AND token_id=?",
+			chainID, contract, acct, id,
+		).
+		First(&h).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	cur := big.NewInt(0)
+	if h.ID != 0 {
+		if _, ok := cur.SetString(h.Amount, 10); !ok {
+			return fmt.Errorf("invalid token_holding amount %q id=%d", h.Amount, h.ID)
+		}
+	}
+
+	before := new(big.Int).Set(cur)
+	cur.Add(cur, delta)
+
+	var anomaly *models.IndexerAnomaly
+	if cur.Sign() < 0 {
+		a := newNegativeHoldingAnomaly(chainID, sysContract, ev, account, tokenID, before, delta, cur)
+
+		switch a.Policy {
+		case models.AnomalyPolicySkip:
+			return recordAnomaly(ctx, tx, &a)
+		case models.AnomalyPolicyClamp:
+			// 截断为 0，token_holding_log 记录实际生效的 delta
+			cur = big.NewInt(0)
+			delta = new(big.Int).Neg(before)
+			anomaly = &a
+		default:
+			return &AnomalyError{Anomaly: a}
+		}
+	}
+
+	if err := tx.Create(&models.TokenHoldingLog{
+		ChainID:         chainID,
+		ContractAddress: contract,
+		Account:         acct,
+		TokenID:         id,
+		Delta:           delta.String(),
+		AmountAfter:     cur.String(),
+		BlockNumber:     int64(ev.BlockNumber),
+		BlockTime:       ev.BlockTime,
+		TxHash:          ev.TxHash.Hex(),
+		LogIndex:        int64(ev.LogIndex),
+		CreatedAt:       time.Now().UTC(),
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := setHolding(tx, chainID, contract, acct, id, cur.String(), int64(ev.BlockNumber), ev.BlockTime); err != nil {
+		return err
+	}
+
+	if anomaly != nil {
+		return recordAnomaly(ctx, tx, anomaly)
+	}
+	return nil
+}
+
+// setHolding 写入持仓快照，数量为 0 时删除
+func setHolding(
+	tx *gorm.DB,
+	chainID int64,
+	contract, account, tokenID, amount string,
+	blockNumber int64,
+	blockTime time.Time,
+) error {
+
+	if amount == "0" {
+		return tx.Where(
+			"chain_id=? AND contract_address=? AND account=? AND token_id=?",
+			chainID, contract, account, tokenID,
+		).Delete(&models.TokenHolding{}).Error
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "chain_id"}, {Name: "contract_address"}, {Name: "account"}, {Name: "token_id"},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"amount", "block_number", "block_time", "updated_at",
+		}),
+	}).Create(&models.TokenHolding{
+		ChainID:         chainID,
+		ContractAddress: contract,
+		Account:         account,
+		TokenID:         tokenID,
+		Amount:          amount,
+		BlockNumber:     blockNumber,
+		BlockTime:       blockTime,
+		UpdatedAt:       time.Now().UTC(),
+	}).Error
+}
+
+// rollbackTokenHoldings 回滚 fork 段持仓流水，并按剩余流水重建受影响的 (account, tokenId)
+func rollbackTokenHoldings(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	ancestor int64,
+) error {
+
+	type key struct {
+		Account string
+		TokenID string
+	}
+
+	var keys []key
+	if err := tx.Model(&models.TokenHoldingLog{}).
+		Distinct("account", "token_id").
+		Where(
+			"chain_id=? AND contract_address=? AND block_number > ?",
+			chainID, contract, ancestor,
+		).
+		Scan(&keys).Error; err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if err := tx.Where(
+		"chain_id=? AND contract_address=? AND block_number > ?",
+		chainID, contract, ancestor,
+	).Delete(&models.TokenHoldingLog{}).Error; err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		var last models.TokenHoldingLog
+		if err := tx.
+			Where(
+				"chain_id=? AND contract_address=? AND account=? AND token_id=?",
+				chainID, contract, k.Account, k.TokenID,
+			).
+			Order("block_number DESC, log_index DESC").
+			Limit(1).
+			Find(&last).Error; err != nil {
+			return err
+		}
+
+		amount := "0"
+		if last.ID != 0 {
+			amount = last.AmountAfter
+		}
+
+		if err := setHolding(
+			tx, chainID, contract, k.Account, k.TokenID, amount, last.BlockNumber, last.BlockTime,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
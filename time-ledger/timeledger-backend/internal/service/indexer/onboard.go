@@ -0,0 +1,266 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	erc20 "github.com/Atom257/web3-labs/timeledger-backend/pkg/contract/erc20"
+)
+
+/*
+Onboarding
+----------
+- 合约首次同步前从链上读取元数据并落到 sys_contracts，之后不再重复读取
+- decimals：通过 binding 的 Decimals() 读取；config 的 token_decimals 仅作为覆盖值
+  - 链上可读且与覆盖值不一致：报错，合约进入退避，等待人工修正配置
+  - 链上不可读（decimals() revert / 返回空）：有覆盖值则采用覆盖值，否则报错
+  - 网络、超时等其他错误：直接返回，合约退避后重试，不能当作未实现
+- NFT 合约按个数计，跳过 decimals
+- name / symbol / owner 同样在首次同步时读取（revert 记为空，网络错误重试）；owner 之后随 OwnershipTransferred 更新
+- start_block 未配置时自动探测部署区块（见 deploy_block.go）
+*/
+
+// onboardContract 读取并固化合约元数据，返回更新后的合约
+func (ix *Indexer) onboardContract(
+	ctx context.Context,
+	client *ethclient.Client,
+	chain models.SysChain,
+	contract models.SysContract,
+) (models.SysContract, error) {
+
+	var err error
+
+	if !contract.HasStartBlock() {
+		if contract, err = ix.onboardStartBlock(ctx, client, chain, contract); err != nil {
+			return contract, err
+		}
+	}
+
+	if !contract.IsNFT() && !contract.DecimalsVerified {
+		if contract, err = ix.onboardDecimals(ctx, client, chain, contract); err != nil {
+			return contract, err
+		}
+	}
+
+	if contract.MetadataSyncedAt == nil {
+		if contract, err = ix.onboardMetadata(ctx, client, chain, contract); err != nil {
+			return contract, err
+		}
+	}
+
+	return contract, nil
+}
+
+// onboardDecimals 核对并写入 decimals
+func (ix *Indexer) onboardDecimals(
+	ctx context.Context,
+	client *ethclient.Client,
+	chain models.SysChain,
+	contract models.SysContract,
+) (models.SysContract, error) {
+
+	decimals, err := ix.resolveDecimals(ctx, client, chain, contract)
+	if err != nil {
+		return contract, err
+	}
+
+	if contract.TokenDecimals != decimals {
+		log.Printf(
+			"[indexer.onboard] decimals changed chain=%d contract=%s %d -> %d",
+			chain.ChainID,
+			contract.Address,
+			contract.TokenDecimals,
+			decimals,
+		)
+	}
+
+	if err := ix.db.WithContext(ctx).
+		Model(&models.SysContract{}).
+		Where("id=?", contract.ID).
+		Updates(map[string]any{
+			"token_decimals":    decimals,
+			"decimals_verified": true,
+			"updated_at":        time.Now().UTC(),
+		}).Error; err != nil {
+		return contract, err
+	}
+
+	log.Printf(
+		"[indexer.onboard] chain=%d contract=%s decimals=%d",
+		chain.ChainID,
+		contract.Address,
+		decimals,
+	)
+
+	contract.TokenDecimals = decimals
+	contract.DecimalsVerified = true
+	return contract, nil
+}
+
+// onboardMetadata 读取 name / symbol / owner
+// 这些方法不是所有合约都实现（如 ERC-1155 没有 name，非 Ownable 没有 owner），revert 时记为空；
+// 网络等其他错误不写 metadata_synced_at，下一轮重试
+func (ix *Indexer) onboardMetadata(
+	ctx context.Context,
+	client *ethclient.Client,
+	chain models.SysChain,
+	contract models.SysContract,
+) (models.SysContract, error) {
+
+	token, err := erc20.NewTimeLedgerToken(common.HexToAddress(contract.Address), client)
+	if err != nil {
+		return contract, err
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+
+	name, nameErr := callRPCWithRetry(ctx, ix.rpcLimiter, "eth_call", chain.ChainID, 0, func() (string, error) {
+		return token.Name(opts)
+	})
+	symbol, symbolErr := callRPCWithRetry(ctx, ix.rpcLimiter, "eth_call", chain.ChainID, 0, func() (string, error) {
+		return token.Symbol(opts)
+	})
+	ownerAddr, ownerErr := callRPCWithRetry(ctx, ix.rpcLimiter, "eth_call", chain.ChainID, 0, func() (common.Address, error) {
+		return token.Owner(opts)
+	})
+
+	// 只有 revert 才算“读不到”，其他错误下一轮重试
+	for _, e := range []error{nameErr, symbolErr, ownerErr} {
+		if e != nil && !isCallRevertErr(e) {
+			return contract, fmt.Errorf("read metadata contract=%s: %w", contract.Address, e)
+		}
+	}
+
+	if nameErr != nil {
+		log.Printf("[indexer.onboard] name() unavailable contract=%s: %v", contract.Address, nameErr)
+	}
+	if symbolErr != nil {
+		log.Printf("[indexer.onboard] symbol() unavailable contract=%s: %v", contract.Address, symbolErr)
+	}
+
+	owner := ""
+	if ownerErr != nil {
+		log.Printf("[indexer.onboard] owner() unavailable contract=%s: %v", contract.Address, ownerErr)
+	} else {
+		owner = ownerAddr.Hex()
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]any{
+		"token_name":         truncate(name, 128),
+		"token_symbol":       truncate(symbol, 32),
+		"owner":              owner,
+		"metadata_synced_at": now,
+		"updated_at":         now,
+	}
+
+	// 未配置别名时用 symbol
+	if contract.Name == "" && symbol != "" {
+		updates["name"] = truncate(symbol, 64)
+		contract.Name = truncate(symbol, 64)
+	}
+
+	if err := ix.db.WithContext(ctx).
+		Model(&models.SysContract{}).
+		Where("id=?", contract.ID).
+		Updates(updates).Error; err != nil {
+		return contract, err
+	}
+
+	log.Printf(
+		"[indexer.onboard] chain=%d contract=%s name=%q symbol=%q owner=%s",
+		chain.ChainID,
+		contract.Address,
+		name,
+		symbol,
+		owner,
+	)
+
+	contract.TokenName = truncate(name, 128)
+	contract.TokenSymbol = truncate(symbol, 32)
+	contract.Owner = owner
+	contract.MetadataSyncedAt = &now
+	return contract, nil
+}
+
+// truncate 按字符截断，防止超出列宽
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// resolveDecimals 链上 decimals() 与配置覆盖值对账
+func (ix *Indexer) resolveDecimals(
+	ctx context.Context,
+	client *ethclient.Client,
+	chain models.SysChain,
+	contract models.SysContract,
+) (int, error) {
+
+	override := ix.decimalsOverride(chain.ChainID, contract.Address)
+
+	token, err := erc20.NewTimeLedgerToken(common.HexToAddress(contract.Address), client)
+	if err != nil {
+		return 0, err
+	}
+
+	onchain, err := callRPCWithRetry(
+		ctx,
+		ix.rpcLimiter,
+		"eth_call",
+		chain.ChainID,
+		0,
+		func() (uint8, error) {
+			return token.Decimals(&bind.CallOpts{Context: ctx})
+		},
+	)
+
+	switch {
+	case err != nil && !isCallRevertErr(err):
+		return 0, fmt.Errorf("read decimals contract=%s: %w", contract.Address, err)
+	case err != nil && override == 0:
+		return 0, fmt.Errorf(
+			"read decimals failed contract=%s (set token_decimals to override): %w",
+			contract.Address, err,
+		)
+	case err != nil:
+		log.Printf(
+			"[indexer.onboard] decimals() unavailable contract=%s, use configured %d: %v",
+			contract.Address, override, err,
+		)
+		return int(override), nil
+	case override > 0 && int64(onchain) != override:
+		return 0, fmt.Errorf(
+			"configured token_decimals=%d but on-chain decimals=%d contract=%s",
+			override, onchain, contract.Address,
+		)
+	default:
+		return int(onchain), nil
+	}
+}
+
+// decimalsOverride 配置中的 token_decimals（0 = 未配置，以链上为准）
+func (ix *Indexer) decimalsOverride(chainID int64, address string) int64 {
+	for _, ch := range ix.cfg.Chains {
+		if ch.ChainID != chainID {
+			continue
+		}
+		for _, c := range ch.Contracts {
+			if strings.EqualFold(c.Address, address) {
+				return c.TokenDecimals
+			}
+		}
+	}
+	return 0
+}
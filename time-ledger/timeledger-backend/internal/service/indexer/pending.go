@@ -3,14 +3,48 @@ package indexer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"sort"
+	"log"
 	"time"
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/redis/go-redis/v9"
 )
 
+/*
+Pending
+-------
+- OP Stack 扫描到的区块先暂存为 pending，<= safe block 后再 flush 落库
+- 暂存由 PendingStore 抽象：
+  - 配置了 Redis：redisPendingStore（pending_redis.go）
+  - 未配置 Redis：dbPendingStore（pending_db.go），写入 pending_block 表
+- 不论哪种实现，OP Stack 都不会把未确认数据直接写入业务表
+*/
+
+// ErrPendingUnavailable pending 区块数据不完整（如 Redis 中已过期），只读调用方应退回已确认数据
+var ErrPendingUnavailable = errors.New("pending blocks unavailable")
+
+// PendingStore OP Stack pending 区块暂存
+type PendingStore interface {
+	// Stage 暂存一个区块（同一区块重复写入时覆盖）
+	Stage(ctx context.Context, block *PendingBlock) error
+
+	// ListUpTo 按区块号升序返回 <= maxBlock 的 pending 区块
+	// minBlock 为已落库的 canonical block，实现可借此清理已落库的残留数据
+	ListUpTo(ctx context.Context, chainID int64, contract string, minBlock int64, maxBlock uint64) ([]*PendingBlock, error)
+
+	// ListAfter 只读：按区块号升序返回 > after 的 pending 区块，不做任何清理（供 API 使用）
+	// 区块数据缺失时返回 ErrPendingUnavailable
+	ListAfter(ctx context.Context, chainID int64, contract string, after int64) ([]*PendingBlock, error)
+
+	// Delete 删除已落库的 pending 区块
+	Delete(ctx context.Context, chainID int64, contract string, bns ...uint64) error
+
+	// DeleteAfter 删除区块号 > after 的 pending 区块，返回数量；dryRun 时只统计
+	DeleteAfter(ctx context.Context, chainID int64, contract string, after int64, dryRun bool) (int64, error)
+}
+
 type PendingHead struct {
 	ChainID         int64     `json:"chain_id"`
 	ContractAddress string    `json:"contract_address"`
@@ -22,7 +56,7 @@ type PendingHead struct {
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
-// PendingBlock 表示 OP Stack 上一个尚未确认的区块数据，仅用于 Redis 缓存
+// PendingBlock 表示 OP Stack 上一个尚未确认的区块数据，由 PendingStore 暂存
 type PendingBlock struct {
 	ChainID         int64  `json:"chain_id"`
 	ContractAddress string `json:"contract_address"`
@@ -35,9 +69,26 @@ type PendingBlock struct {
 	// 区块内的 Transfer 事件
 	Events []TransferEvent `json:"events"`
 
+	// 区块内的 Approval / OwnershipTransferred 事件
+	Approvals  []ApprovalEvent  `json:"approvals,omitempty"`
+	Ownerships []OwnershipEvent `json:"ownerships,omitempty"`
+
+	// 区块内按 ABI 解码的通用事件
+	ContractEvents []ContractLogEvent `json:"contract_events,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// chunkEvents 还原为 applyChunkTx 使用的事件集合
+func (pb *PendingBlock) chunkEvents() ChunkEvents {
+	return ChunkEvents{
+		Transfers:  pb.Events,
+		Approvals:  pb.Approvals,
+		Ownerships: pb.Ownerships,
+		Contract:   pb.ContractEvents,
+	}
+}
+
 // UpdatePendingHead 将 OP Stack 当前最新 head 写入 Redis，仅用于观测链状态
 func (ix *Indexer) UpdatePendingHead(
 	ctx context.Context,
@@ -75,119 +126,15 @@ func (ix *Indexer) UpdatePendingHead(
 	return rdb.Set(ctx, key, b, 10*time.Second).Err()
 }
 
-// StagePendingBlock 将未确认区块写入 Redis，用于 OP Stack 数据暂存
-func (ix *Indexer) StagePendingBlock(
-	ctx context.Context,
-	rdb *redis.Client,
-	block *PendingBlock,
-) error {
-
-	key := fmt.Sprintf(
-		"%s:pending:block:%d:%s:%d",
-		ix.cfg.Redis.KeyPrefix, // 需要前缀
-		block.ChainID,
-		block.ContractAddress,
-		block.BlockNumber,
-	)
-
-	data, err := json.Marshal(block)
-	if err != nil {
-		return err
-	}
-
-	// pending 区块设置不设置过期时间，靠主动清除
-	return rdb.Set(ctx, key, data, 0).Err()
-}
-
-// ListPendingBlocksUpTo 读取指定高度之前的 pending 区块
-func (ix *Indexer) ListPendingBlocksUpTo(
-	ctx context.Context,
-	rdb *redis.Client,
-	chainID int64,
-	contract string,
-	maxBlock uint64,
-) ([]*PendingBlock, error) {
-
-	pattern := fmt.Sprintf(
-		"%s:pending:block:%d:%s:*",
-		ix.cfg.Redis.KeyPrefix,
-		chainID,
-		contract,
-	)
-
-	var blocks []*PendingBlock
-
-	// 用 SCAN 代替 KEYS，避免 Redis 阻塞
-	iter := rdb.Scan(ctx, 0, pattern, 200).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
-
-		val, err := rdb.Get(ctx, key).Bytes()
-		if err != nil {
-			continue
-		}
-
-		var pb PendingBlock
-		if err := json.Unmarshal(val, &pb); err != nil {
-			continue
-		}
-
-		if pb.BlockNumber <= maxBlock {
-			blocks = append(blocks, &pb)
-		}
-	}
-
-	if err := iter.Err(); err != nil {
-		return nil, err
-	}
-
-	sort.Slice(blocks, func(i, j int) bool {
-		return blocks[i].BlockNumber < blocks[j].BlockNumber
-	})
-
-	return blocks, nil
-}
-
 // CleanupPendingAfterReorg
-// 清理 Redis 中 reorg 发生点之后的 pending 区块数据
+// 清理 reorg 发生点之后的 pending 区块数据
 func (ix *Indexer) CleanupPendingAfterReorg(
 	ctx context.Context,
-	rdb *redis.Client,
 	chainID int64,
 	contract string,
 	ancestor int64,
 ) {
-
-	if rdb == nil {
-		return
+	if _, err := ix.pending.DeleteAfter(ctx, chainID, contract, ancestor, false); err != nil {
+		log.Printf("[opstack.pending] cleanup failed contract=%s: %v", contract, err)
 	}
-
-	pattern := fmt.Sprintf(
-		"%s:pending:block:%d:%s:*",
-		ix.cfg.Redis.KeyPrefix,
-		chainID,
-		contract,
-	)
-
-	// 用 SCAN 代替 KEYS，避免 Redis 阻塞
-	iter := rdb.Scan(ctx, 0, pattern, 200).Iterator()
-	for iter.Next(ctx) {
-		key := iter.Val()
-
-		val, err := rdb.Get(ctx, key).Bytes()
-		if err != nil {
-			continue
-		}
-
-		var pb PendingBlock
-		if err := json.Unmarshal(val, &pb); err != nil {
-			continue
-		}
-
-		if int64(pb.BlockNumber) > ancestor {
-			_ = rdb.Del(ctx, key).Err()
-		}
-	}
-
-	_ = iter.Err()
 }
@@ -0,0 +1,156 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Pending Store (DB)
+------------------
+- 未配置 Redis 时使用，pending 区块写入 pending_block 表
+- 与业务表在同一个库中，但 flush 之前不会影响余额 / 积分
+- 同一区块重复暂存（如 reorg 后重扫）按唯一索引覆盖
+*/
+
+type dbPendingStore struct {
+	db *gorm.DB
+}
+
+func newDBPendingStore(db *gorm.DB) *dbPendingStore {
+	return &dbPendingStore{db: db}
+}
+
+func (s *dbPendingStore) Stage(ctx context.Context, block *PendingBlock) error {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "chain_id"}, {Name: "contract_address"}, {Name: "block_number"},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{"block_hash", "payload", "updated_at"}),
+	}).Create(&models.PendingBlock{
+		ChainID:         block.ChainID,
+		ContractAddress: block.ContractAddress,
+		BlockNumber:     int64(block.BlockNumber),
+		BlockHash:       block.BlockHash,
+		Payload:         data,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}).Error
+}
+
+func (s *dbPendingStore) ListUpTo(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	minBlock int64,
+	maxBlock uint64,
+) ([]*PendingBlock, error) {
+
+	var rows []models.PendingBlock
+	if err := s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND block_number <= ?",
+			chainID, contract, maxBlock,
+		).
+		Order("block_number ASC").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*PendingBlock, 0, len(rows))
+	for _, r := range rows {
+		var pb PendingBlock
+		if err := json.Unmarshal(r.Payload, &pb); err != nil {
+			return nil, fmt.Errorf("decode pending block %d: %w", r.BlockNumber, err)
+		}
+		blocks = append(blocks, &pb)
+	}
+
+	return blocks, nil
+}
+
+func (s *dbPendingStore) ListAfter(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	after int64,
+) ([]*PendingBlock, error) {
+
+	var rows []models.PendingBlock
+	if err := s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND block_number > ?",
+			chainID, contract, after,
+		).
+		Order("block_number ASC").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*PendingBlock, 0, len(rows))
+	for _, r := range rows {
+		var pb PendingBlock
+		if err := json.Unmarshal(r.Payload, &pb); err != nil {
+			return nil, fmt.Errorf("decode pending block %d: %w", r.BlockNumber, err)
+		}
+		blocks = append(blocks, &pb)
+	}
+
+	return blocks, nil
+}
+
+func (s *dbPendingStore) Delete(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	bns ...uint64,
+) error {
+
+	if len(bns) == 0 {
+		return nil
+	}
+
+	return s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND block_number IN ?",
+			chainID, contract, bns,
+		).
+		Delete(&models.PendingBlock{}).Error
+}
+
+func (s *dbPendingStore) DeleteAfter(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	after int64,
+	dryRun bool,
+) (int64, error) {
+
+	q := s.db.WithContext(ctx).
+		Where(
+			"chain_id=? AND contract_address=? AND block_number > ?",
+			chainID, contract, after,
+		)
+
+	if dryRun {
+		var n int64
+		err := q.Model(&models.PendingBlock{}).Count(&n).Error
+		return n, err
+	}
+
+	res := q.Delete(&models.PendingBlock{})
+	return res.RowsAffected, res.Error
+}
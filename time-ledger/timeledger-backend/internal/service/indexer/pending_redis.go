@@ -0,0 +1,364 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+Pending Store (Redis)
+---------------------
+- 区块数据：{prefix}:pending:block:{chain}:{contract}:{bn} -> PendingBlock JSON
+- 区块索引：{prefix}:pending:index:{chain}:{contract}      -> ZSET，member / score 均为区块号
+- 范围读取 / 删除 / reorg 清理走 ZRANGEBYSCORE + pipeline，不再 SCAN 整个 keyspace
+- 区块与索引都带 TTL（redis.pending_ttl_sec，默认 24h），每次写入时续期；
+  合约停止索引后遗留的 key 到期自动回收
+- 索引里存在、区块 key 已过期的 member 视为孤儿：已落库的直接移除，
+  未落库的说明数据已丢失，返回错误，需 rewind 到该区块之前重新扫描
+- 升级前写入的无索引 key，每个进程首次访问该合约时用 SCAN 补建一次索引
+*/
+
+// defaultPendingTTL pending 区块默认保留时长
+const defaultPendingTTL = 24 * time.Hour
+
+// errPendingExpired 索引中的 pending 区块在落库前已过期
+var errPendingExpired = errors.New("pending block expired before flush")
+
+type redisPendingStore struct {
+	rdb       *redis.Client
+	keyPrefix string
+	ttl       time.Duration
+
+	// 已补建索引的合约（key = pending index key）
+	indexed sync.Map
+}
+
+func newRedisPendingStore(rdb *redis.Client, keyPrefix string, ttlSec int64) *redisPendingStore {
+	ttl := defaultPendingTTL
+	if ttlSec > 0 {
+		ttl = time.Duration(ttlSec) * time.Second
+	}
+	return &redisPendingStore{
+		rdb:       rdb,
+		keyPrefix: keyPrefix,
+		ttl:       ttl,
+	}
+}
+
+func (s *redisPendingStore) blockKey(chainID int64, contract string, bn uint64) string {
+	return fmt.Sprintf(
+		"%s:pending:block:%d:%s:%d",
+		s.keyPrefix,
+		chainID,
+		contract,
+		bn,
+	)
+}
+
+func (s *redisPendingStore) indexKey(chainID int64, contract string) string {
+	return fmt.Sprintf(
+		"%s:pending:index:%d:%s",
+		s.keyPrefix,
+		chainID,
+		contract,
+	)
+}
+
+// Stage 区块与索引在同一个 MULTI 中写入，并续期索引
+func (s *redisPendingStore) Stage(ctx context.Context, block *PendingBlock) error {
+	data, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	key := s.blockKey(block.ChainID, block.ContractAddress, block.BlockNumber)
+	idx := s.indexKey(block.ChainID, block.ContractAddress)
+
+	_, err = s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, key, data, s.ttl)
+		p.ZAdd(ctx, idx, redis.Z{
+			Score:  float64(block.BlockNumber),
+			Member: strconv.FormatUint(block.BlockNumber, 10),
+		})
+		p.Expire(ctx, idx, s.ttl)
+		return nil
+	})
+	return err
+}
+
+// ListUpTo 索引中 <= minBlock 的孤儿 member 顺带清理；
+// (minBlock, maxBlock] 内区块数据已过期时返回 errPendingExpired
+func (s *redisPendingStore) ListUpTo(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	minBlock int64,
+	maxBlock uint64,
+) ([]*PendingBlock, error) {
+
+	if err := s.ensureIndex(ctx, chainID, contract); err != nil {
+		return nil, err
+	}
+
+	idx := s.indexKey(chainID, contract)
+
+	members, err := s.rdb.ZRangeByScore(ctx, idx, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatUint(maxBlock, 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	bns := make([]uint64, 0, len(members))
+	keys := make([]string, 0, len(members))
+	for _, m := range members {
+		bn, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			// 非法 member，直接移除
+			_ = s.rdb.ZRem(ctx, idx, m).Err()
+			continue
+		}
+		bns = append(bns, bn)
+		keys = append(keys, s.blockKey(chainID, contract, bn))
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	vals, err := s.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		blocks  []*PendingBlock
+		orphans []uint64
+	)
+
+	for i, v := range vals {
+		bn := bns[i]
+
+		str, ok := v.(string)
+		if !ok {
+			// 已落库：上次删除未完成，清理索引即可
+			if int64(bn) <= minBlock {
+				orphans = append(orphans, bn)
+				continue
+			}
+			return nil, fmt.Errorf(
+				"%w: chain=%d contract=%s block=%d",
+				errPendingExpired, chainID, contract, bn,
+			)
+		}
+
+		var pb PendingBlock
+		if err := json.Unmarshal([]byte(str), &pb); err != nil {
+			return nil, fmt.Errorf("decode pending block %d: %w", bn, err)
+		}
+		blocks = append(blocks, &pb)
+	}
+
+	if len(orphans) > 0 {
+		if err := s.Delete(ctx, chainID, contract, orphans...); err != nil {
+			log.Printf("[opstack.pending] orphan cleanup failed contract=%s: %v", contract, err)
+		}
+	}
+
+	return blocks, nil
+}
+
+// ListAfter 只读取索引与区块数据：不补建索引、不清理孤儿（由 indexer 的 ListUpTo 负责）
+func (s *redisPendingStore) ListAfter(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	after int64,
+) ([]*PendingBlock, error) {
+
+	members, err := s.rdb.ZRangeByScore(ctx, s.indexKey(chainID, contract), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(after, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(members))
+	for _, m := range members {
+		bn, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, s.blockKey(chainID, contract, bn))
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	vals, err := s.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*PendingBlock, 0, len(vals))
+	for i, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s expired", ErrPendingUnavailable, keys[i])
+		}
+
+		var pb PendingBlock
+		if err := json.Unmarshal([]byte(str), &pb); err != nil {
+			return nil, fmt.Errorf("decode pending block %s: %w", keys[i], err)
+		}
+		blocks = append(blocks, &pb)
+	}
+	return blocks, nil
+}
+
+// Delete 数据与索引一起删除
+func (s *redisPendingStore) Delete(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	bns ...uint64,
+) error {
+
+	if len(bns) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(bns))
+	members := make([]any, 0, len(bns))
+	for _, bn := range bns {
+		keys = append(keys, s.blockKey(chainID, contract, bn))
+		members = append(members, strconv.FormatUint(bn, 10))
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, keys...)
+		p.ZRem(ctx, s.indexKey(chainID, contract), members...)
+		return nil
+	})
+	return err
+}
+
+func (s *redisPendingStore) DeleteAfter(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	after int64,
+	dryRun bool,
+) (int64, error) {
+
+	if err := s.ensureIndex(ctx, chainID, contract); err != nil {
+		return 0, err
+	}
+
+	idx := s.indexKey(chainID, contract)
+	min := "(" + strconv.FormatInt(after, 10)
+
+	members, err := s.rdb.ZRangeByScore(ctx, idx, &redis.ZRangeBy{
+		Min: min,
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if dryRun || len(members) == 0 {
+		return int64(len(members)), nil
+	}
+
+	keys := make([]string, 0, len(members))
+	for _, m := range members {
+		if bn, err := strconv.ParseUint(m, 10, 64); err == nil {
+			keys = append(keys, s.blockKey(chainID, contract, bn))
+		}
+	}
+
+	_, err = s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if len(keys) > 0 {
+			p.Del(ctx, keys...)
+		}
+		p.ZRemRangeByScore(ctx, idx, min, "+inf")
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(members)), nil
+}
+
+// ensureIndex 为升级前写入的 pending 区块补建索引（每个进程每个合约一次）
+// 同时给这些无过期时间的旧 key 补上 TTL
+func (s *redisPendingStore) ensureIndex(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+) error {
+
+	idx := s.indexKey(chainID, contract)
+	if _, done := s.indexed.Load(idx); done {
+		return nil
+	}
+
+	prefix := fmt.Sprintf(
+		"%s:pending:block:%d:%s:",
+		s.keyPrefix,
+		chainID,
+		contract,
+	)
+
+	var n int
+
+	iter := s.rdb.Scan(ctx, 0, prefix+"*", 200).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		// 区块号取自 key 后缀，无需读取内容
+		bn, err := strconv.ParseUint(strings.TrimPrefix(key, prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if _, err := s.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+			p.ZAdd(ctx, idx, redis.Z{
+				Score:  float64(bn),
+				Member: strconv.FormatUint(bn, 10),
+			})
+			p.Expire(ctx, key, s.ttl)
+			return nil
+		}); err != nil {
+			return err
+		}
+		n++
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if n > 0 {
+		if err := s.rdb.Expire(ctx, idx, s.ttl).Err(); err != nil {
+			return err
+		}
+		log.Printf("[opstack.pending] backfilled index contract=%s blocks=%d", contract, n)
+	}
+
+	s.indexed.Store(idx, struct{}{})
+	return nil
+}
@@ -0,0 +1,58 @@
+package indexer
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PendingChange 某账户在 pending 区块中的一次余额变化（未确认）
+type PendingChange struct {
+	BlockNumber  uint64
+	BlockTime    time.Time
+	TxHash       string
+	LogIndex     uint
+	Delta        *big.Int
+	BalanceAfter *big.Int
+}
+
+// PendingBalanceChanges 在 base（已确认余额）之上按顺序叠加 pending Transfer，返回每一次变化
+// 与 transfer 模式落库一致：from 减、to 加，零地址不计；blocks 需按区块号升序
+// snapshot 模式的合约没有 pending 快照，结果按事件金额估算
+func PendingBalanceChanges(
+	blocks []*PendingBlock,
+	account common.Address,
+	base *big.Int,
+) []PendingChange {
+
+	cur := new(big.Int).Set(base)
+
+	var out []PendingChange
+	for _, pb := range blocks {
+		for _, ev := range pb.Events {
+			delta := new(big.Int)
+			if ev.From == account && ev.From != zeroAddr {
+				delta.Sub(delta, ev.Value)
+			}
+			if ev.To == account && ev.To != zeroAddr {
+				delta.Add(delta, ev.Value)
+			}
+			if ev.From != account && ev.To != account {
+				continue
+			}
+
+			cur = new(big.Int).Add(cur, delta)
+			out = append(out, PendingChange{
+				BlockNumber:  ev.BlockNumber,
+				BlockTime:    ev.BlockTime,
+				TxHash:       ev.TxHash.Hex(),
+				LogIndex:     ev.LogIndex,
+				Delta:        delta,
+				BalanceAfter: cur,
+			})
+		}
+	}
+
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"math/big"
 	"time"
 
@@ -76,7 +77,7 @@ func (ix *Indexer) EnsureCanonicalOrRollback(
 	}
 
 	// 找 common ancestor
-	ancestor, ancestorHash, err := ix.findCommonAncestor(ctx, client, chainID, contractAddr, n, reorgWindow)
+	ancestor, ancestorHash, newHeadHash, err := ix.findCommonAncestor(ctx, client, chainID, contractAddr, n, reorgWindow)
 	if err != nil {
 		return err
 	}
@@ -89,7 +90,8 @@ func (ix *Indexer) EnsureCanonicalOrRollback(
 	// 记录 OP Stack reorg 发生
 	if ix.redis != nil {
 		key := fmt.Sprintf(
-			"reorg:seen:%d:%s",
+			"%s:reorg:seen:%d:%s",
+			ix.cfg.Redis.KeyPrefix,
 			chainID,
 			contractAddr,
 		)
@@ -101,8 +103,19 @@ func (ix *Indexer) EnsureCanonicalOrRollback(
 		)
 	}
 
+	reorg := &models.ReorgEvent{
+		ChainID:         chainID,
+		ContractAddress: contractAddr,
+		AncestorBlock:   ancestor,
+		AncestorHash:    ancestorHash,
+		HeadBlock:       n,
+		OldHeadHash:     cur.BlockHash,
+		NewHeadHash:     newHeadHash,
+		Depth:           n - ancestor,
+	}
+
 	// 发生分叉，执行 rollback
-	return ix.rollbackTo(ctx, client, chainID, contractAddr, ancestor, ancestorHash)
+	return ix.rollbackTo(ctx, client, chainID, contractAddr, reorg)
 }
 
 func (ix *Indexer) findCommonAncestor(
@@ -112,13 +125,16 @@ func (ix *Indexer) findCommonAncestor(
 	contractAddr string,
 	cursor int64,
 	reorgWindow int64,
-) (int64, string, error) {
+) (int64, string, string, error) {
 
 	low := cursor - reorgWindow
 	if low < 0 {
 		low = 0
 	}
 
+	// cursor 高度上当前链的 hash（记录 reorg 用）
+	var headHash string
+
 	for bn := cursor; bn >= low; bn-- {
 		// DB hash
 		var bh models.BlockHeader
@@ -133,7 +149,7 @@ func (ix *Indexer) findCommonAncestor(
 			continue
 		}
 		if err != nil {
-			return 0, "", err
+			return 0, "", "", err
 		}
 
 		// chain hash
@@ -149,182 +165,64 @@ func (ix *Indexer) findCommonAncestor(
 		)
 
 		if err != nil {
-			return 0, "", err
+			return 0, "", "", err
 		}
 
 		chainHash := hdr.Hash().Hex()
+		if bn == cursor {
+			headHash = chainHash
+		}
 		if bh.BlockHash == chainHash {
-			return bn, chainHash, nil
+			return bn, chainHash, headHash, nil
 		}
 	}
 
-	return 0, "", fmt.Errorf("no common ancestor within reorg_window=%d blocks", reorgWindow)
+	return 0, "", "", fmt.Errorf("no common ancestor within reorg_window=%d blocks", reorgWindow)
 }
 
+// rollbackTo reorg 回滚到 common ancestor（与手动 rewind 共用 rewindTx）
+// reorg 记录与回滚在同一事务中写入
 func (ix *Indexer) rollbackTo(
 	ctx context.Context,
 	client *ethclient.Client,
 	chainID int64,
 	contractAddr string,
-	ancestor int64,
-	ancestorHash string,
+	reorg *models.ReorgEvent,
 ) error {
 
-	// 1️⃣ 先执行数据库回滚（原有逻辑，完全不动）
-	err := ix.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-
-		// 删除 fork 段 block_header
-		if err := tx.Where(
-			"chain_id=? AND contract_address=? AND block_number > ?",
-			chainID, contractAddr, ancestor,
-		).Delete(&models.BlockHeader{}).Error; err != nil {
-			return err
-		}
-
-		// 删除 fork 段 balance_log
-		if err := tx.Where(
-			"chain_id=? AND contract_address=? AND block_number > ?",
-			chainID, contractAddr, ancestor,
-		).Delete(&models.BalanceLog{}).Error; err != nil {
-			return err
-		}
-
-		// 删除 fork 段对应时间之后的积分日志
-		var ancHeader models.BlockHeader
-		if err := tx.
-			Select("block_time").
-			Where(
-				"chain_id=? AND contract_address=? AND block_number=?",
-				chainID, contractAddr, ancestor,
-			).
-			First(&ancHeader).Error; err != nil {
-			return err
-		}
-		ancestorTime := ancHeader.BlockTime.UTC()
-
-		if err := tx.Where(
-			"chain_id=? AND contract_address=? AND to_time > ?",
-			chainID, contractAddr, ancestorTime,
-		).Delete(&models.UserPointLog{}).Error; err != nil {
-			return err
-		}
-
-		// 重建 user_balance
-		if err := tx.Where(
-			"chain_id=? AND contract_address=?",
-			chainID, contractAddr,
-		).Delete(&models.UserBalance{}).Error; err != nil {
-			return err
-		}
-
-		type row struct {
-			Account      string
-			BalanceAfter string
-			BlockNumber  int64
-			BlockTime    time.Time
-		}
-
-		var rows []row
-		sub := tx.Model(&models.BalanceLog{}).
-			Select("account, MAX(block_number) AS max_bn").
-			Where("chain_id=? AND contract_address=?", chainID, contractAddr).
-			Group("account")
-
-		if err := tx.Table("balance_log bl").
-			Select("bl.account, bl.balance_after, bl.block_number, bl.block_time").
-			Joins("JOIN (?) m ON bl.account = m.account AND bl.block_number = m.max_bn", sub).
-			Where("bl.chain_id=? AND bl.contract_address=?", chainID, contractAddr).
-			Scan(&rows).Error; err != nil {
-			return err
-		}
-
-		now := time.Now().UTC()
-		for _, r := range rows {
-			ub := models.UserBalance{
-				ChainID:         chainID,
-				ContractAddress: contractAddr,
-				Account:         r.Account,
-				Balance:         r.BalanceAfter,
-				BlockNumber:     r.BlockNumber,
-				BlockTime:       r.BlockTime.UTC(),
-				UpdatedAt:       now,
-			}
-			if err := tx.Create(&ub).Error; err != nil {
-				return err
-			}
-		}
-
-		// 重建 user_point
-		if err := tx.Where(
-			"chain_id=? AND contract_address=?",
-			chainID, contractAddr,
-		).Delete(&models.UserPoint{}).Error; err != nil {
-			return err
-		}
-
-		type pointAggRow struct {
-			Account      string
-			TotalPoints  string
-			LastCalcTime time.Time
-		}
-
-		var pointRows []pointAggRow
-		if err := tx.Table("user_point_log AS upl").
-			Select(`
-				upl.account AS account,
-				SUM(upl.points) AS total_points,
-				MAX(upl.to_time) AS last_calc_time
-			`).
-			Where("upl.chain_id=? AND upl.contract_address=?", chainID, contractAddr).
-			Group("upl.account").
-			Scan(&pointRows).Error; err != nil {
-			return err
-		}
-
-		for _, r := range pointRows {
-			up := models.UserPoint{
-				ChainID:         chainID,
-				ContractAddress: contractAddr,
-				Account:         r.Account,
-				TotalPoints:     r.TotalPoints,
-				LastCalcTime:    r.LastCalcTime.UTC(),
-				UpdatedAt:       now,
-			}
-			if err := tx.Create(&up).Error; err != nil {
-				return err
-			}
-		}
-
-		// 更新 cursor（最后一步）
-		if err := tx.Model(&models.BlockCursor{}).
-			Where("chain_id=? AND contract_address=?", chainID, contractAddr).
-			Updates(map[string]any{
-				"block_number":      ancestor,
-				"block_hash":        ancestorHash,
-				"scan_block_number": ancestor,     // 必须回退
-				"last_block_time":   ancestorTime, // 回滚时也要修正时间。之前查出来的 ancestorTime
-				"updated_at":        now,
-			}).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
-
-	if err != nil {
+	var sysContract models.SysContract
+	if err := ix.db.WithContext(ctx).
+		Where("chain_id=? AND address=?", chainID, contractAddr).
+		First(&sysContract).Error; err != nil {
 		return err
 	}
 
-	//	DB 回滚成功后，再清理 Redis
-	if ix.redis != nil {
-		ix.CleanupPendingAfterReorg(
-			ctx,
-			ix.redis,
-			chainID,
-			contractAddr,
-			ancestor,
-		)
+	// 1️⃣ 先执行数据库回滚
+	if _, err := ix.rewindTx(ctx, sysContract, reorg.AncestorBlock, reorg.AncestorHash, false, reorg); err != nil {
+		return err
 	}
 
+	observeReorg(reorg)
+
+	log.Printf(
+		"[indexer.reorg] chain=%d contract=%s ancestor=%d depth=%d old=%s new=%s balance_logs=%d point_logs=%d",
+		chainID,
+		contractAddr,
+		reorg.AncestorBlock,
+		reorg.Depth,
+		reorg.OldHeadHash,
+		reorg.NewHeadHash,
+		reorg.BalanceLogsReverted,
+		reorg.PointLogsReverted,
+	)
+
+	//	DB 回滚成功后，再清理 pending
+	ix.CleanupPendingAfterReorg(
+		ctx,
+		chainID,
+		contractAddr,
+		reorg.AncestorBlock,
+	)
+
 	return nil
 }
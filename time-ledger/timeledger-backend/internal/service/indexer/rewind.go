@@ -0,0 +1,585 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/lease"
+)
+
+/*
+Rewind / Reindex
+----------------
+- reorg 回滚与运维手动回退共用 rewindTx：把合约的全部派生数据回退到目标区块
+  - 按区块删除：block_header / balance_log / transfer_event / contract_event / token_supply / indexer_anomaly，
+    并回滚 approval / ownership / NFT 持仓（同时修正对应快照）
+  - 按时间删除：积分分表 user_point_log_{id} 中 to_time 晚于目标区块时间的记录
+  - 重建：user_balance（取剩余 balance_log 的最新一条）、user_point（取剩余积分日志汇总）
+  - 最后回退 cursor
+- Rewind：回退到任意目标区块；Reindex：回退到 start_block - 1，即清空后从头重建
+- dry-run 只统计受影响行数（含将重建的 user_balance / user_point 行数），不做任何修改，也不占用租约
+- 执行期间持有该合约的 indexer 租约，并占用进程内合约锁，避免与正在运行的同步并发：
+  - 在运行 indexer 的进程内（POST /admin/contracts/rewind）直接接管本进程持有的租约
+  - 在独立进程（ledgerctl）中执行时，需先停止负责该合约的 indexer（或等待其租约过期），否则返回 ErrContractBusy
+- 同时以独立身份占用该合约的 calculator 租约，user_point 重建期间常规计算与重算都会跳过该合约
+*/
+
+var (
+	// ErrContractBusy 合约正在被其他实例（或本进程）同步，无法回退
+	ErrContractBusy = errors.New("contract is being indexed by another worker; stop the indexer for this contract (or wait for its lease to expire) first")
+
+	// ErrCalculatorBusy 合约积分正在计算，回退会与 user_point 的写入冲突
+	ErrCalculatorBusy = errors.New("contract points are being calculated; retry after the current run finishes")
+)
+
+// RewindResult 回退结果（dry-run 时为预计影响的行数）
+type RewindResult struct {
+	ChainID       int64            `json:"chain_id"`
+	Contract      string           `json:"contract"`
+	CursorBlock   int64            `json:"cursor_block"` // 回退前的 cursor
+	TargetBlock   int64            `json:"target_block"`
+	TargetTime    time.Time        `json:"target_time"`
+	DryRun        bool             `json:"dry_run"`
+	Rows          map[string]int64 `json:"rows"`           // 表名 -> 删除（或重建）的行数
+	PendingBlocks int64            `json:"pending_blocks"` // 被清理的 OP Stack pending 区块数
+}
+
+// Rewind 将合约回退到 target 区块（保留 <= target 的数据）
+func (ix *Indexer) Rewind(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	target int64,
+	dryRun bool,
+) (RewindResult, error) {
+
+	sysContract, err := ix.loadSysContract(ctx, chainID, contract)
+	if err != nil {
+		return RewindResult{}, err
+	}
+
+	if target < sysContract.StartBlock-1 {
+		return RewindResult{}, fmt.Errorf(
+			"target block %d is before start_block %d",
+			target, sysContract.StartBlock,
+		)
+	}
+
+	return ix.rewindContract(ctx, sysContract, target, dryRun)
+}
+
+// Reindex 清空合约的全部索引数据，从 start_block 重新同步
+func (ix *Indexer) Reindex(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	dryRun bool,
+) (RewindResult, error) {
+
+	sysContract, err := ix.loadSysContract(ctx, chainID, contract)
+	if err != nil {
+		return RewindResult{}, err
+	}
+
+	if !sysContract.HasStartBlock() {
+		return RewindResult{}, fmt.Errorf("start_block of %s is not resolved yet", contract)
+	}
+
+	return ix.rewindContract(ctx, sysContract, sysContract.StartBlock-1, dryRun)
+}
+
+func (ix *Indexer) loadSysContract(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+) (models.SysContract, error) {
+
+	var c models.SysContract
+	err := ix.db.WithContext(ctx).
+		Where("chain_id=? AND address=?", chainID, contract).
+		First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c, fmt.Errorf("contract %s on chain %d not configured", contract, chainID)
+	}
+	return c, err
+}
+
+// contractMutex 进程内合约锁：同步 worker 与手动回退互斥
+func (ix *Indexer) contractMutex(chainID int64, contract string) *sync.Mutex {
+	mu, _ := ix.contractLocks.LoadOrStore(syncStatusKey(chainID, contract), &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+func (ix *Indexer) rewindContract(
+	ctx context.Context,
+	sysContract models.SysContract,
+	target int64,
+	dryRun bool,
+) (RewindResult, error) {
+
+	chainID := sysContract.ChainID
+	addr := sysContract.Address
+
+	// dry-run 只读，不与同步 / 计算互斥
+	if dryRun {
+		return ix.rewindLocked(ctx, sysContract, target, true)
+	}
+
+	// 进程内互斥
+	mu := ix.contractMutex(chainID, addr)
+	if !mu.TryLock() {
+		return RewindResult{}, ErrContractBusy
+	}
+	defer mu.Unlock()
+
+	// 跨实例互斥：持有 indexer 租约，写事务做 fencing
+	l, ok, err := ix.leases.Acquire(ctx, lease.IndexerName(chainID, addr))
+	if err != nil {
+		return RewindResult{}, err
+	}
+	if !ok {
+		return RewindResult{}, ErrContractBusy
+	}
+	defer ix.leases.ReleaseLease(context.WithoutCancel(ctx), l)
+	ctx = lease.WithLease(ctx, l)
+
+	// 与 calculator 互斥：重建 user_point 期间不允许计算写入
+	cl, ok, err := ix.leases.AcquireAs(ctx, lease.CalculatorName(chainID, addr), "rewind")
+	if err != nil {
+		return RewindResult{}, err
+	}
+	if !ok {
+		return RewindResult{}, ErrCalculatorBusy
+	}
+	defer ix.leases.ReleaseLease(context.WithoutCancel(ctx), cl)
+	ctx = lease.WithFence(ctx, cl)
+
+	return ix.rewindLocked(ctx, sysContract, target, false)
+}
+
+// rewindLocked 校验 cursor 后执行回退（非 dry-run 时调用方已持有租约）
+func (ix *Indexer) rewindLocked(
+	ctx context.Context,
+	sysContract models.SysContract,
+	target int64,
+	dryRun bool,
+) (RewindResult, error) {
+
+	chainID := sysContract.ChainID
+	addr := sysContract.Address
+
+	var cursor models.BlockCursor
+	if err := ix.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=?", chainID, addr).
+		First(&cursor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return RewindResult{}, fmt.Errorf("contract %s has not been indexed yet", addr)
+		}
+		return RewindResult{}, err
+	}
+
+	if target >= cursor.BlockNumber {
+		return RewindResult{}, fmt.Errorf(
+			"target block %d is not behind cursor %d",
+			target, cursor.BlockNumber,
+		)
+	}
+
+	// 目标区块有 header 时沿用其 hash；否则留空，由下次同步的 ensureCursorHash 补齐
+	var bh models.BlockHeader
+	if err := ix.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=? AND block_number=?", chainID, addr, target).
+		Limit(1).
+		Find(&bh).Error; err != nil {
+		return RewindResult{}, err
+	}
+
+	res, err := ix.rewindTx(ctx, sysContract, target, bh.BlockHash, dryRun, nil)
+	if err != nil {
+		return RewindResult{}, err
+	}
+	res.CursorBlock = cursor.BlockNumber
+
+	// pending 区块：DB 回退成功后再清理
+	res.PendingBlocks, err = ix.pending.DeleteAfter(ctx, chainID, addr, target, dryRun)
+	if err != nil {
+		return res, fmt.Errorf("cleanup pending blocks: %w", err)
+	}
+
+	log.Printf(
+		"[indexer.rewind] chain=%d contract=%s cursor=%d target=%d dry_run=%v rows=%v pending=%d",
+		chainID,
+		addr,
+		res.CursorBlock,
+		target,
+		dryRun,
+		res.Rows,
+		res.PendingBlocks,
+	)
+
+	return res, nil
+}
+
+// rewindTx 在一个事务内把合约派生数据回退到 target（dry-run 仅统计）
+// targetHash 为空时 cursor.block_hash 置空，由 ensureCursorHash 补齐
+// reorg 非空时（链重组触发），补全回滚行数后在同一事务中写入 reorg_event
+func (ix *Indexer) rewindTx(
+	ctx context.Context,
+	sysContract models.SysContract,
+	target int64,
+	targetHash string,
+	dryRun bool,
+	reorg *models.ReorgEvent,
+) (RewindResult, error) {
+
+	chainID := sysContract.ChainID
+	contractAddr := sysContract.Address
+	logTableName := sysContract.GetLogTableName()
+
+	res := RewindResult{
+		ChainID:     chainID,
+		Contract:    contractAddr,
+		TargetBlock: target,
+		DryRun:      dryRun,
+		Rows:        make(map[string]int64),
+	}
+
+	// 按区块回滚的表
+	blockScoped := []struct {
+		name  string
+		model any
+	}{
+		{"block_header", &models.BlockHeader{}},
+		{"balance_log", &models.BalanceLog{}},
+		{"transfer_event", &models.TransferEvent{}},
+		{"contract_event", &models.ContractEvent{}},
+		{"approval_log", &models.ApprovalLog{}},
+		{"ownership_history", &models.OwnershipHistory{}},
+		{"token_holding_log", &models.TokenHoldingLog{}},
+		{"token_supply", &models.TokenSupply{}},
+		{"indexer_anomaly", &models.IndexerAnomaly{}},
+	}
+
+	err := ix.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+
+		// fencing：租约已易主则拒绝回滚
+		if !dryRun {
+			if err := ix.leases.CheckFence(ctx, tx); err != nil {
+				return err
+			}
+		}
+
+		// 目标区块时间：<= target 的最新 block_header；没有则为 0（全部积分重算）
+		var tgtHeader models.BlockHeader
+		if err := tx.
+			Select("block_time").
+			Where(
+				"chain_id=? AND contract_address=? AND block_number <= ?",
+				chainID, contractAddr, target,
+			).
+			Order("block_number DESC").
+			Limit(1).
+			Find(&tgtHeader).Error; err != nil {
+			return err
+		}
+		targetTime := time.Unix(0, 0).UTC()
+		if !tgtHeader.BlockTime.IsZero() {
+			targetTime = tgtHeader.BlockTime.UTC()
+		}
+		res.TargetTime = targetTime
+
+		// 统计受影响行数
+		for _, t := range blockScoped {
+			var n int64
+			if err := tx.Model(t.model).
+				Where(
+					"chain_id=? AND contract_address=? AND block_number > ?",
+					chainID, contractAddr, target,
+				).
+				Count(&n).Error; err != nil {
+				return err
+			}
+			res.Rows[t.name] = n
+		}
+
+		hasLogTable := tx.Migrator().HasTable(logTableName)
+		if hasLogTable {
+			var n int64
+			if err := tx.Table(logTableName).
+				Where(
+					"chain_id=? AND contract_address=? AND to_time > ?",
+					chainID, contractAddr, targetTime,
+				).
+				Count(&n).Error; err != nil {
+				return err
+			}
+			res.Rows[logTableName] = n
+		}
+
+		if dryRun {
+			// 回退后将重建的行数
+			n, err := countRebuiltBalances(tx, chainID, contractAddr, target)
+			if err != nil {
+				return err
+			}
+			res.Rows["user_balance"] = n
+
+			n = 0
+			if hasLogTable {
+				if n, err = countRebuiltPoints(tx, chainID, contractAddr, logTableName, targetTime); err != nil {
+					return err
+				}
+			}
+			res.Rows["user_point"] = n
+			return nil
+		}
+
+		if reorg != nil {
+			reorg.BalanceLogsReverted = res.Rows["balance_log"]
+			reorg.PointLogsReverted = res.Rows[logTableName]
+			reorg.DetectedAt = time.Now().UTC()
+			if err := tx.Create(reorg).Error; err != nil {
+				return err
+			}
+		}
+
+		// 删除 fork 段 block_header / balance_log / 原始事件 / 供应量 / 异常
+		for _, model := range []any{
+			&models.BlockHeader{},
+			&models.BalanceLog{},
+			&models.TransferEvent{},
+			&models.ContractEvent{},
+			&models.TokenSupply{},
+			&models.IndexerAnomaly{},
+		} {
+			if err := tx.Where(
+				"chain_id=? AND contract_address=? AND block_number > ?",
+				chainID, contractAddr, target,
+			).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// 回滚 fork 段 NFT 持仓，并重建受影响的 (account, tokenId)
+		if err := rollbackTokenHoldings(tx, chainID, contractAddr, target); err != nil {
+			return err
+		}
+
+		// 回滚 fork 段 Approval / OwnershipTransferred，并修正授权快照
+		if err := rollbackTokenEvents(tx, chainID, contractAddr, target); err != nil {
+			return err
+		}
+
+		// 删除目标时间之后的积分日志（分表）
+		if hasLogTable {
+			if err := tx.Table(logTableName).
+				Where(
+					"chain_id=? AND contract_address=? AND to_time > ?",
+					chainID, contractAddr, targetTime,
+				).
+				Delete(&models.UserPointLog{}).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC()
+
+		// 重建 user_balance
+		n, err := rebuildUserBalances(tx, chainID, contractAddr, now)
+		if err != nil {
+			return err
+		}
+		res.Rows["user_balance"] = n
+
+		// 重建 user_point
+		n, err = rebuildUserPoints(tx, chainID, contractAddr, logTableName, hasLogTable, now)
+		if err != nil {
+			return err
+		}
+		res.Rows["user_point"] = n
+
+		// 更新 cursor（最后一步）
+		return tx.Model(&models.BlockCursor{}).
+			Where("chain_id=? AND contract_address=?", chainID, contractAddr).
+			Updates(map[string]any{
+				"block_number":      target,
+				"block_hash":        targetHash,
+				"scan_block_number": target,     // 必须回退
+				"last_block_time":   targetTime, // 回退时也要修正时间
+				"updated_at":        now,
+			}).Error
+	})
+
+	return res, err
+}
+
+// rebuildUserBalances 按剩余 balance_log 重建 user_balance，返回重建行数
+// 同一区块可能有多条记录，取 (block_number, log_index) 最大的一条
+func rebuildUserBalances(
+	tx *gorm.DB,
+	chainID int64,
+	contractAddr string,
+	now time.Time,
+) (int64, error) {
+
+	if err := tx.Where(
+		"chain_id=? AND contract_address=?",
+		chainID, contractAddr,
+	).Delete(&models.UserBalance{}).Error; err != nil {
+		return 0, err
+	}
+
+	type row struct {
+		Account      string
+		BalanceAfter string
+		BlockNumber  int64
+		BlockTime    time.Time
+	}
+
+	var rows []row
+	sub := tx.Model(&models.BalanceLog{}).
+		Select("account, MAX(block_number) AS max_bn").
+		Where("chain_id=? AND contract_address=?", chainID, contractAddr).
+		Group("account")
+
+	if err := tx.Table("balance_log bl").
+		Select("bl.account, bl.balance_after, bl.block_number, bl.block_time").
+		Joins("JOIN (?) m ON bl.account = m.account AND bl.block_number = m.max_bn", sub).
+		Where("bl.chain_id=? AND bl.contract_address=?", chainID, contractAddr).
+		Order("bl.account, bl.log_index").
+		Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	// 按 log_index 升序，同账户后出现的覆盖先出现的
+	latest := make(map[string]row, len(rows))
+	order := make([]string, 0, len(rows))
+	for _, r := range rows {
+		if _, ok := latest[r.Account]; !ok {
+			order = append(order, r.Account)
+		}
+		latest[r.Account] = r
+	}
+
+	for _, acct := range order {
+		r := latest[acct]
+		ub := models.UserBalance{
+			ChainID:         chainID,
+			ContractAddress: contractAddr,
+			Account:         r.Account,
+			Balance:         r.BalanceAfter,
+			BlockNumber:     r.BlockNumber,
+			BlockTime:       r.BlockTime.UTC(),
+			UpdatedAt:       now,
+		}
+		if err := tx.Create(&ub).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(order)), nil
+}
+
+// countRebuiltBalances dry-run：回退到 target 后 user_balance 的行数（保留 balance_log 的账户数）
+func countRebuiltBalances(
+	tx *gorm.DB,
+	chainID int64,
+	contractAddr string,
+	target int64,
+) (int64, error) {
+
+	var n int64
+	err := tx.Model(&models.BalanceLog{}).
+		Where(
+			"chain_id=? AND contract_address=? AND block_number <= ?",
+			chainID, contractAddr, target,
+		).
+		Distinct("account").
+		Count(&n).Error
+	return n, err
+}
+
+// countRebuiltPoints dry-run：回退到 targetTime 后 user_point 的行数（保留积分日志的账户数）
+func countRebuiltPoints(
+	tx *gorm.DB,
+	chainID int64,
+	contractAddr string,
+	logTableName string,
+	targetTime time.Time,
+) (int64, error) {
+
+	var n int64
+	err := tx.Table(logTableName).
+		Where(
+			"chain_id=? AND contract_address=? AND to_time <= ?",
+			chainID, contractAddr, targetTime,
+		).
+		Distinct("account").
+		Count(&n).Error
+	return n, err
+}
+
+// rebuildUserPoints 按剩余积分日志重建 user_point，返回重建行数
+// 没有剩余日志的账户不建 user_point，由 calculator 从首次出现时间重新初始化
+func rebuildUserPoints(
+	tx *gorm.DB,
+	chainID int64,
+	contractAddr string,
+	logTableName string,
+	hasLogTable bool,
+	now time.Time,
+) (int64, error) {
+
+	if err := tx.Where(
+		"chain_id=? AND contract_address=?",
+		chainID, contractAddr,
+	).Delete(&models.UserPoint{}).Error; err != nil {
+		return 0, err
+	}
+
+	if !hasLogTable {
+		return 0, nil
+	}
+
+	type pointAggRow struct {
+		Account      string
+		TotalPoints  string
+		LastCalcTime time.Time
+	}
+
+	var pointRows []pointAggRow
+	if err := tx.Table(logTableName+" AS upl").
+		Select(`
+			upl.account AS account,
+			SUM(upl.points) AS total_points,
+			MAX(upl.to_time) AS last_calc_time
+		`).
+		Where("upl.chain_id=? AND upl.contract_address=?", chainID, contractAddr).
+		Group("upl.account").
+		Scan(&pointRows).Error; err != nil {
+		return 0, err
+	}
+
+	for _, r := range pointRows {
+		up := models.UserPoint{
+			ChainID:         chainID,
+			ContractAddress: contractAddr,
+			Account:         r.Account,
+			TotalPoints:     r.TotalPoints,
+			LastCalcTime:    r.LastCalcTime.UTC(),
+			UpdatedAt:       now,
+		}
+		if err := tx.Create(&up).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(pointRows)), nil
+}
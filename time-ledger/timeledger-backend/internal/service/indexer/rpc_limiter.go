@@ -3,9 +3,13 @@ package indexer
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 /*
@@ -14,6 +18,8 @@ RPC Limiter + Retry
 - 全局 RPS 限制
 - 每次 RPC 最多 3 次重试
 - 一旦识别为 rate limit，立即返回 ErrRateLimited
+- 合约调用 revert / 无代码 / 返回空数据：结果是确定的，不重试，原样返回
+- 重试耗尽时返回包装了最后一次错误的 error，调用方仍可据此判断错误类型
 */
 
 var ErrRateLimited = errors.New("rpc rate limited")
@@ -58,6 +64,7 @@ func (l *RPCLimiter) Wait(ctx context.Context) error {
 // - 最多 3 次尝试
 // - 指数退避：100ms / 200ms / 400ms
 // - 一旦识别为 rate limit，立即返回 ErrRateLimited
+// - 一旦识别为合约调用 revert，立即返回原错误
 func callRPCWithRetry[T any](
 	ctx context.Context,
 	limiter *RPCLimiter,
@@ -67,7 +74,10 @@ func callRPCWithRetry[T any](
 	fn func() (T, error),
 ) (T, error) {
 
-	var zero T
+	var (
+		zero    T
+		lastErr error
+	)
 
 	backoff := []time.Duration{
 		100 * time.Millisecond,
@@ -112,6 +122,12 @@ func callRPCWithRetry[T any](
 			return zero, ErrRateLimited
 		}
 
+		// 合约执行 revert：重试结果相同
+		if isCallRevertErr(err) {
+			return zero, err
+		}
+		lastErr = err
+
 		// 普通错误：重试
 		log.Printf(
 			"[rpc.retry] chain_id=%d rpc=%s block=%d cost_ms=%d attempt=%d backoff_ms=%d err=%v",
@@ -127,7 +143,7 @@ func callRPCWithRetry[T any](
 		time.Sleep(backoff[i])
 	}
 
-	return zero, errors.New("rpc retry exhausted")
+	return zero, fmt.Errorf("rpc retry exhausted: %w", lastErr)
 }
 
 // 判断是否为 RPC 限流错误
@@ -140,3 +156,22 @@ func isRateLimitErr(err error) bool {
 		strings.Contains(msg, "rate limit") ||
 		strings.Contains(msg, "too many requests")
 }
+
+// isCallRevertErr 合约调用确定性失败：执行 revert、地址无合约代码或返回空数据
+// 网络 / 超时 / 5xx 等错误不算，调用方应当重试而不是认定合约未实现该方法
+func isCallRevertErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, bind.ErrNoCode) {
+		return true
+	}
+	// geth 对带 revert data 的执行失败返回 code 3
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "execution reverted") ||
+		strings.Contains(msg, "attempting to unmarshal an empty string")
+}
@@ -0,0 +1,42 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+type testRPCError struct {
+	code int
+	msg  string
+}
+
+func (e testRPCError) Error() string  { return e.msg }
+func (e testRPCError) ErrorCode() int { return e.code }
+
+// 只有确定性的调用失败才能当作合约未实现该方法
+func TestIsCallRevertErr(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no code", fmt.Errorf("rpc retry exhausted: %w", bind.ErrNoCode), true},
+		{"revert with data", testRPCError{3, "execution reverted: Ownable"}, true},
+		{"revert without data", testRPCError{-32000, "execution reverted"}, true},
+		{"empty return data", errors.New("abi: attempting to unmarshal an empty string while arguments are expected"), true},
+		{"dial error", errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"), false},
+		{"http 5xx", errors.New("502 Bad Gateway: upstream error"), false},
+		{"timeout", fmt.Errorf("rpc retry exhausted: %w", context.DeadlineExceeded), false},
+		{"rate limited", ErrRateLimited, false},
+	}
+
+	for _, tc := range cases {
+		if got := isCallRevertErr(tc.err); got != tc.want {
+			t.Errorf("%s: isCallRevertErr = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
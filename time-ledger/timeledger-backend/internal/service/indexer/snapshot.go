@@ -0,0 +1,223 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	erc20 "github.com/Atom257/web3-labs/timeledger-backend/pkg/contract/erc20"
+)
+
+/*
+Snapshot Accounting
+-------------------
+- 针对 rebase（如 stETH）与转账收税代币：Transfer 累加结果与 balanceOf 不一致
+- snapshot 模式下 Transfer 只作为“账户被触达”的信号，
+  事务前按 (block, account) 调用 balanceOf(block)，balance_log 直接记录观测余额
+- 同一区块内多次触达：首条记录承担整块的变化，其余 delta 为 0
+- 可选全量核对（rebase sweep）：每跨过 rebase_sweep_blocks 的整数倍，
+  在 chunk 末块对所有持仓账户调用 balanceOf，不一致时补一条哨兵 log_index 的 balance_log
+- 历史区块的 eth_call 需要 archive 节点
+*/
+
+// rebaseSweepLogIndex 全量核对写入 balance_log 的哨兵 log_index（排在同块所有真实 log 之后）
+const rebaseSweepLogIndex = math.MaxInt32
+
+type balanceSnapKey struct {
+	Block   uint64
+	Account common.Address
+}
+
+// chunkSnapshots snapshot 模式下事务前拉取的链上余额
+type chunkSnapshots struct {
+	balances map[balanceSnapKey]*big.Int
+
+	// 全量核对：sweepBlock = 0 表示本 chunk 不核对
+	sweepBlock uint64
+	sweep      map[common.Address]*big.Int
+}
+
+// isSnapshotMode 合约是否按 balanceOf 快照记账
+func isSnapshotMode(c models.SysContract) bool {
+	return c.AccountingMode == models.AccountingModeSnapshot
+}
+
+// fetchSnapshots 拉取本 chunk 内被触达账户在对应区块的余额，以及（如需）全量核对余额
+func (ix *Indexer) fetchSnapshots(
+	ctx context.Context,
+	client *ethclient.Client,
+	chainID int64,
+	sysContract models.SysContract,
+	end uint64,
+	transfers []TransferEvent,
+) (*chunkSnapshots, error) {
+
+	token, err := erc20.NewTimeLedgerToken(common.HexToAddress(sysContract.Address), client)
+	if err != nil {
+		return nil, err
+	}
+
+	balanceAt := func(account common.Address, bn uint64) (*big.Int, error) {
+		return callRPCWithRetry(
+			ctx,
+			ix.rpcLimiter,
+			"eth_call",
+			chainID,
+			bn,
+			func() (*big.Int, error) {
+				return token.BalanceOf(&bind.CallOpts{
+					Context:     ctx,
+					BlockNumber: new(big.Int).SetUint64(bn),
+				}, account)
+			},
+		)
+	}
+
+	snaps := &chunkSnapshots{balances: make(map[balanceSnapKey]*big.Int)}
+
+	for _, ev := range transfers {
+		for _, acct := range []common.Address{ev.From, ev.To} {
+			if acct == zeroAddr {
+				continue
+			}
+			key := balanceSnapKey{Block: ev.BlockNumber, Account: acct}
+			if _, ok := snaps.balances[key]; ok {
+				continue
+			}
+
+			bal, err := balanceAt(acct, ev.BlockNumber)
+			if err != nil {
+				return nil, err
+			}
+			snaps.balances[key] = bal
+		}
+	}
+
+	// 全量核对：cursor 到 end 之间跨过了 N 的整数倍
+	n := uint64(sysContract.RebaseSweepBlocks)
+	if n == 0 {
+		return snaps, nil
+	}
+
+	var cursor models.BlockCursor
+	if err := ix.db.WithContext(ctx).
+		Select("block_number").
+		Where("chain_id=? AND contract_address=?", chainID, sysContract.Address).
+		First(&cursor).Error; err != nil {
+		return nil, err
+	}
+	if uint64(cursor.BlockNumber)/n >= end/n {
+		return snaps, nil
+	}
+
+	// 所有持仓账户 + 本 chunk 触达的账户
+	var accounts []string
+	if err := ix.db.WithContext(ctx).
+		Model(&models.UserBalance{}).
+		Where("chain_id=? AND contract_address=? AND balance > 0", chainID, sysContract.Address).
+		Pluck("account", &accounts).Error; err != nil {
+		return nil, err
+	}
+
+	targets := make(map[common.Address]struct{}, len(accounts))
+	for _, a := range accounts {
+		targets[common.HexToAddress(a)] = struct{}{}
+	}
+	for key := range snaps.balances {
+		targets[key.Account] = struct{}{}
+	}
+
+	snaps.sweepBlock = end
+	snaps.sweep = make(map[common.Address]*big.Int, len(targets))
+	for acct := range targets {
+		bal, err := balanceAt(acct, end)
+		if err != nil {
+			return nil, err
+		}
+		snaps.sweep[acct] = bal
+	}
+
+	return snaps, nil
+}
+
+// applyAccountSnapshot 以观测余额覆盖账户余额，delta = 观测值 - 当前值
+func (ix *Indexer) applyAccountSnapshot(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	snaps *chunkSnapshots,
+	ev TransferEvent,
+	account common.Address,
+) error {
+
+	observed, ok := snaps.balances[balanceSnapKey{Block: ev.BlockNumber, Account: account}]
+	if !ok {
+		return fmt.Errorf("missing balance snapshot acct=%s block=%d", account.Hex(), ev.BlockNumber)
+	}
+
+	ub, err := lockUserBalance(tx, chainID, contract, account)
+	if err != nil {
+		return err
+	}
+
+	before, _ := new(big.Int).SetString(ub.Balance, 10)
+	delta := new(big.Int).Sub(observed, before)
+
+	_, err = writeBalanceChange(tx, chainID, contract, &ub, ev, account, delta, new(big.Int).Set(observed))
+	return err
+}
+
+// applyRebaseSweep 全量核对：余额与链上不一致的账户补一条 balance_log
+func (ix *Indexer) applyRebaseSweep(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	snaps *chunkSnapshots,
+	blockTime time.Time,
+) error {
+
+	// 固定顺序，便于排查
+	accounts := make([]common.Address, 0, len(snaps.sweep))
+	for acct := range snaps.sweep {
+		accounts = append(accounts, acct)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Hex() < accounts[j].Hex()
+	})
+
+	ev := TransferEvent{
+		BlockNumber: snaps.sweepBlock,
+		LogIndex:    rebaseSweepLogIndex,
+		BlockTime:   blockTime,
+	}
+
+	for _, acct := range accounts {
+		observed := snaps.sweep[acct]
+
+		ub, err := lockUserBalance(tx, chainID, contract, acct)
+		if err != nil {
+			return err
+		}
+
+		before, _ := new(big.Int).SetString(ub.Balance, 10)
+		if before.Cmp(observed) == 0 {
+			continue
+		}
+
+		delta := new(big.Int).Sub(observed, before)
+		if _, err := writeBalanceChange(tx, chainID, contract, &ub, ev, acct, delta, new(big.Int).Set(observed)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package indexer
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+)
+
+/*
+Token Supply
+------------
+- 以 zeroAddr 识别 mint / burn，按区块汇总写入 token_supply
+- 必须在该区块所有 Transfer 应用完之后、下一区块任何 Transfer 应用之前落库，holder_count 才是区块结束时的持有人数
+  （由 applyTransfers 保证：每个事件先 observe 再应用余额变动）
+*/
+
+// supplyChange 一个区块内累计的 mint / burn
+type supplyChange struct {
+	BlockNumber uint64
+	BlockTime   time.Time
+	Minted      *big.Int
+	Burned      *big.Int
+}
+
+// supplyTracker 在 applyChunkTx 的 Transfer 循环中按区块汇总 mint / burn
+type supplyTracker struct {
+	cur *supplyChange
+
+	// 落库一个区块的汇总（默认 applySupplyChange）
+	apply func(c *supplyChange) error
+}
+
+func newSupplyTracker(tx *gorm.DB, chainID int64, contract string) *supplyTracker {
+	return &supplyTracker{
+		apply: func(c *supplyChange) error {
+			return applySupplyChange(tx, chainID, contract, c)
+		},
+	}
+}
+
+// applyTransfers 按顺序应用 Transfer：每个事件先 observe（进入新区块时落库上一区块），
+// 再调用 apply 应用余额 / 持仓变动，全部应用后落库最后一个区块
+func (t *supplyTracker) applyTransfers(
+	transfers []TransferEvent,
+	apply func(ev TransferEvent) error,
+) error {
+	for _, ev := range transfers {
+		if err := t.observe(ev); err != nil {
+			return err
+		}
+		if err := apply(ev); err != nil {
+			return err
+		}
+	}
+	return t.flush()
+}
+
+// observe 在 Transfer 应用之前调用；进入新区块时先落库上一区块
+func (t *supplyTracker) observe(ev TransferEvent) error {
+	if t.cur != nil && t.cur.BlockNumber != ev.BlockNumber {
+		if err := t.flush(); err != nil {
+			return err
+		}
+	}
+
+	kind := transferKind(ev)
+	if kind != models.TransferKindMint && kind != models.TransferKindBurn {
+		return nil
+	}
+
+	if t.cur == nil {
+		t.cur = &supplyChange{
+			BlockNumber: ev.BlockNumber,
+			BlockTime:   ev.BlockTime,
+			Minted:      new(big.Int),
+			Burned:      new(big.Int),
+		}
+	}
+
+	if kind == models.TransferKindMint {
+		t.cur.Minted.Add(t.cur.Minted, ev.Value)
+	} else {
+		t.cur.Burned.Add(t.cur.Burned, ev.Value)
+	}
+	return nil
+}
+
+// flush 落库当前区块（chunk 结束时也必须调用）
+func (t *supplyTracker) flush() error {
+	if t.cur == nil {
+		return nil
+	}
+	c := t.cur
+	t.cur = nil
+
+	return t.apply(c)
+}
+
+// applySupplyChange 基于上一条记录累计 total_supply，并统计当前持有人数
+func applySupplyChange(
+	tx *gorm.DB,
+	chainID int64,
+	contract string,
+	c *supplyChange,
+) error {
+
+	var prev models.TokenSupply
+	if err := tx.
+		Where(
+			"chain_id=? AND contract_address=? AND block_number < ?",
+			chainID, contract, c.BlockNumber,
+		).
+		Order("block_number DESC").
+		Limit(1).
+		Find(&prev).Error; err != nil {
+		return err
+	}
+
+	total := big.NewInt(0)
+	if prev.ID != 0 {
+		if _, ok := total.SetString(prev.TotalSupply, 10); !ok {
+			return fmt.Errorf("invalid token_supply total_supply %q id=%d", prev.TotalSupply, prev.ID)
+		}
+	}
+	total.Add(total, c.Minted)
+	total.Sub(total, c.Burned)
+
+	var holders int64
+	if err := tx.Model(&models.UserBalance{}).
+		Where("chain_id=? AND contract_address=? AND balance > 0", chainID, contract).
+		Count(&holders).Error; err != nil {
+		return err
+	}
+
+	return tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.TokenSupply{
+			ChainID:         chainID,
+			ContractAddress: contract,
+			BlockNumber:     int64(c.BlockNumber),
+			BlockTime:       c.BlockTime,
+			Minted:          c.Minted.String(),
+			Burned:          c.Burned.String(),
+			TotalSupply:     total.String(),
+			HolderCount:     holders,
+			CreatedAt:       time.Now().UTC(),
+		}).Error
+}
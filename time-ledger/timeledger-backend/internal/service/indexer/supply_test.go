@@ -0,0 +1,48 @@
+package indexer
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 连续两个区块：第二个区块的 mint 产生新持有人，第一个区块的 holder_count 不能包含它
+func TestApplyTransfersFlushesBeforeNextBlock(t *testing.T) {
+	a := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	b := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	now := time.Now().UTC()
+
+	transfers := []TransferEvent{
+		{BlockNumber: 1, LogIndex: 0, From: zeroAddr, To: a, Value: big.NewInt(10), BlockTime: now},
+		{BlockNumber: 2, LogIndex: 0, From: zeroAddr, To: b, Value: big.NewInt(5), BlockTime: now.Add(time.Second)},
+	}
+
+	holders := make(map[common.Address]bool)
+	counts := make(map[uint64]int)
+
+	tracker := &supplyTracker{
+		apply: func(c *supplyChange) error {
+			counts[c.BlockNumber] = len(holders)
+			return nil
+		},
+	}
+
+	err := tracker.applyTransfers(transfers, func(ev TransferEvent) error {
+		if ev.To != zeroAddr {
+			holders[ev.To] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("apply transfers: %v", err)
+	}
+
+	if counts[1] != 1 {
+		t.Fatalf("block 1 holder_count = %d, want 1", counts[1])
+	}
+	if counts[2] != 2 {
+		t.Fatalf("block 2 holder_count = %d, want 2", counts[2])
+	}
+}
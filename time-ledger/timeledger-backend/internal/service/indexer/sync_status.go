@@ -0,0 +1,221 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/service/events"
+)
+
+/*
+Sync Status
+-----------
+- 每次 syncContract 结束后更新 sync_status
+- 成功：按 lag 区分 catching_up / caught_up，清零失败计数
+- 失败：累计失败次数，连续失败达到阈值进入 stalled
+- 失败后按指数退避设置 next_retry_at，退避期内跳过该合约，避免空转
+*/
+
+const (
+	// 连续失败多少次视为 stalled
+	syncStalledThreshold = 3
+
+	// 退避：5s, 10s, 20s ... 最长 10 分钟
+	syncBackoffBase = 5 * time.Second
+	syncBackoffMax  = 10 * time.Minute
+)
+
+// syncBackoff 根据连续失败次数计算退避时长
+func syncBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	d := syncBackoffBase
+	for i := 1; i < failures; i++ {
+		d *= 2
+		if d >= syncBackoffMax {
+			return syncBackoffMax
+		}
+	}
+	return d
+}
+
+// syncStatusKey 用于内存中按 chain + contract 索引状态
+func syncStatusKey(chainID int64, contract string) string {
+	return fmt.Sprintf("%d:%s", chainID, contract)
+}
+
+// loadSyncStatuses 读取全部同步状态
+func (ix *Indexer) loadSyncStatuses(ctx context.Context) (map[string]models.SyncStatus, error) {
+	var rows []models.SyncStatus
+	if err := ix.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]models.SyncStatus, len(rows))
+	for _, r := range rows {
+		out[syncStatusKey(r.ChainID, r.ContractAddress)] = r
+	}
+	return out, nil
+}
+
+// inBackoff 判断合约是否仍处于失败退避期
+func inBackoff(st models.SyncStatus, now time.Time) bool {
+	return st.NextRetryAt != nil && st.NextRetryAt.After(now)
+}
+
+// recordSyncSuccess 同步成功：根据 cursor 与 safe block 计算 lag 并更新状态
+func (ix *Indexer) recordSyncSuccess(
+	ctx context.Context,
+	chain models.SysChain,
+	contract models.SysContract,
+	safeBlock uint64,
+) {
+
+	var cursor models.BlockCursor
+	if err := ix.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=?", chain.ChainID, contract.Address).
+		First(&cursor).Error; err != nil {
+		log.Printf("[indexer.status] load cursor failed contract=%s: %v", contract.Address, err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	lagBlocks := int64(safeBlock) - cursor.BlockNumber
+	if lagBlocks < 0 {
+		lagBlocks = 0
+	}
+
+	var lagSeconds int64
+	if cursor.LastBlockTime.Unix() > 0 {
+		lagSeconds = int64(now.Sub(cursor.LastBlockTime).Seconds())
+		if lagSeconds < 0 {
+			lagSeconds = 0
+		}
+	}
+
+	// 落后不超过一个 chunk 视为已追上
+	state := models.SyncStateCaughtUp
+	if lagBlocks > int64(chain.ChunkSize) {
+		state = models.SyncStateCatchingUp
+	}
+
+	st := models.SyncStatus{
+		ChainID:             chain.ChainID,
+		ContractAddress:     contract.Address,
+		State:               state,
+		LastError:           "",
+		ConsecutiveFailures: 0,
+		LastAttemptAt:       now,
+		LastSuccessAt:       &now,
+		NextRetryAt:         nil,
+		CursorBlock:         cursor.BlockNumber,
+		SafeBlock:           int64(safeBlock),
+		LagBlocks:           lagBlocks,
+		LagSeconds:          lagSeconds,
+		UpdatedAt:           now,
+	}
+
+	if err := ix.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "chain_id"}, {Name: "contract_address"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"state", "last_error", "consecutive_failures",
+			"last_attempt_at", "last_success_at", "next_retry_at",
+			"cursor_block", "safe_block", "lag_blocks", "lag_seconds", "updated_at",
+		}),
+	}).Create(&st).Error; err != nil {
+		log.Printf("[indexer.status] update failed contract=%s: %v", contract.Address, err)
+	}
+}
+
+// recordSyncFailure 同步失败：累计失败次数并设置退避
+func (ix *Indexer) recordSyncFailure(
+	ctx context.Context,
+	chain models.SysChain,
+	contract models.SysContract,
+	syncErr error,
+) {
+
+	// 上层 ctx 可能已被取消（如限流中断整条链），状态仍需落库
+	ctx = context.WithoutCancel(ctx)
+
+	var st models.SyncStatus
+	err := ix.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=?", chain.ChainID, contract.Address).
+		First(&st).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("[indexer.status] load failed contract=%s: %v", contract.Address, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	failures := st.ConsecutiveFailures + 1
+	retryAt := now.Add(syncBackoff(failures))
+
+	state := models.SyncStateError
+	if failures >= syncStalledThreshold {
+		state = models.SyncStateStalled
+	}
+
+	msg := truncate(syncErr.Error(), 2000)
+
+	st.ChainID = chain.ChainID
+	st.ContractAddress = contract.Address
+	st.State = state
+	st.LastError = msg
+	st.ConsecutiveFailures = failures
+	st.LastAttemptAt = now
+	st.NextRetryAt = &retryAt
+	st.UpdatedAt = now
+
+	if err := ix.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "chain_id"}, {Name: "contract_address"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"state", "last_error", "consecutive_failures",
+			"last_attempt_at", "next_retry_at", "updated_at",
+		}),
+	}).Create(&st).Error; err != nil {
+		log.Printf("[indexer.status] update failed contract=%s: %v", contract.Address, err)
+		return
+	}
+
+	log.Printf(
+		"[indexer.status] chain=%d contract=%s state=%s failures=%d retry_at=%s",
+		chain.ChainID,
+		contract.Address,
+		state,
+		failures,
+		retryAt.Format(time.RFC3339),
+	)
+}
+
+// publishCursorAdvanced 通知 cursor 已推进，失败只记录日志（calculator 仍有定时兜底）
+func (ix *Indexer) publishCursorAdvanced(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+	block int64,
+) {
+	if ix.bus == nil {
+		return
+	}
+
+	err := ix.bus.PublishCursorAdvanced(context.WithoutCancel(ctx), events.CursorAdvanced{
+		ChainID:     chainID,
+		Contract:    contract,
+		BlockNumber: block,
+		At:          time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("[indexer.events] publish cursor_advanced failed contract=%s: %v", contract, err)
+	}
+}
@@ -0,0 +1,228 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/config"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+)
+
+/*
+Keeper
+------
+- 每个服务（indexer / calculator）一个 Keeper，按合约获取租约
+- 租约长期持有并在每轮续期；实例崩溃后租约过期，由其他副本接管
+- max_owned_contracts > 0 时限制单实例持有数量，多个副本分担合约；
+  为 0 时先到先得，其余副本热备
+- 写事务通过 CheckFence 校验 fencing token，拒绝已失去租约的旧持有者
+*/
+
+const defaultLeaseTTL = 60 * time.Second
+
+type Keeper struct {
+	repo     repository.LeaseRepository
+	owner    string
+	ttl      time.Duration
+	maxOwned int
+
+	mu    sync.Mutex
+	owned map[string]*repository.Lease
+
+	// 同一租约可能被多个 goroutine 续约（如 calculator 分片），串行化 ExpiresAt 的读写
+	renewMu sync.Mutex
+}
+
+// NewKeeper 创建租约管理器
+func NewKeeper(db *gorm.DB, cfg config.CoordinationConfig) *Keeper {
+	ttl := defaultLeaseTTL
+	if cfg.LeaseTTLSec > 0 {
+		ttl = time.Duration(cfg.LeaseTTLSec) * time.Second
+	}
+
+	owner := cfg.InstanceID
+	if owner == "" {
+		host, _ := os.Hostname()
+		owner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	return &Keeper{
+		repo:     repository.NewLeaseRepo(db),
+		owner:    owner,
+		ttl:      ttl,
+		maxOwned: cfg.MaxOwnedContracts,
+		owned:    make(map[string]*repository.Lease),
+	}
+}
+
+// Owner 返回本实例标识
+func (k *Keeper) Owner() string { return k.owner }
+
+// Acquire 获取（或续期）租约，返回 false 表示由其他实例持有或已达持有上限
+func (k *Keeper) Acquire(ctx context.Context, name string) (*repository.Lease, bool, error) {
+	k.mu.Lock()
+	_, held := k.owned[name]
+	full := k.maxOwned > 0 && len(k.owned) >= k.maxOwned
+	k.mu.Unlock()
+
+	// 已达上限时只续期已持有的租约，不再抢新的
+	if !held && full {
+		return nil, false, nil
+	}
+
+	l, ok, err := k.repo.TryAcquire(ctx, name, k.owner, k.ttl)
+	if err != nil {
+		return nil, false, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !ok {
+		if held {
+			log.Printf("[lease] lost name=%s owner=%s", name, k.owner)
+		}
+		delete(k.owned, name)
+		return nil, false, nil
+	}
+
+	if !held {
+		log.Printf("[lease] acquired name=%s owner=%s token=%d", name, k.owner, l.Token)
+	}
+	k.owned[name] = l
+	return l, true, nil
+}
+
+// AcquireAs 以 owner/role 的身份获取租约，不计入持有数量
+// 用于运维操作占用其他服务的租约：同进程内的服务（同一 owner）持有时同样视为占用
+func (k *Keeper) AcquireAs(ctx context.Context, name, role string) (*repository.Lease, bool, error) {
+	return k.repo.TryAcquire(ctx, name, k.owner+"/"+role, k.ttl)
+}
+
+// Renew 在剩余有效期不足一半时续约，长任务在循环中调用
+func (k *Keeper) Renew(ctx context.Context) error {
+	l := FromContext(ctx)
+	if l == nil {
+		return nil
+	}
+
+	k.renewMu.Lock()
+	defer k.renewMu.Unlock()
+
+	if time.Until(l.ExpiresAt) > k.ttl/2 {
+		return nil
+	}
+
+	if err := k.repo.Renew(ctx, l, k.ttl); err != nil {
+		if errors.Is(err, repository.ErrLeaseLost) {
+			k.forget(l.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Release 释放本实例持有的全部租约（优雅退出时调用）
+func (k *Keeper) Release(ctx context.Context) {
+	k.mu.Lock()
+	leases := make([]*repository.Lease, 0, len(k.owned))
+	for _, l := range k.owned {
+		leases = append(leases, l)
+	}
+	k.owned = make(map[string]*repository.Lease)
+	k.mu.Unlock()
+
+	for _, l := range leases {
+		if err := k.repo.Release(ctx, l); err != nil {
+			log.Printf("[lease] release failed name=%s: %v", l.Name, err)
+		}
+	}
+}
+
+// ReleaseLease 释放单个租约（如手动运维操作结束后）
+func (k *Keeper) ReleaseLease(ctx context.Context, l *repository.Lease) {
+	k.forget(l.Name)
+	if err := k.repo.Release(ctx, l); err != nil {
+		log.Printf("[lease] release failed name=%s: %v", l.Name, err)
+	}
+}
+
+// CheckFence 在写事务内校验 ctx 中携带的租约（含 WithFence 附加的），ctx 无租约时不校验
+func (k *Keeper) CheckFence(ctx context.Context, tx *gorm.DB) error {
+	leases := fencesFromContext(ctx)
+	if l := FromContext(ctx); l != nil {
+		leases = append(leases, l)
+	}
+
+	for _, l := range leases {
+		if err := k.repo.CheckFence(tx, l); err != nil {
+			if errors.Is(err, repository.ErrLeaseLost) {
+				k.forget(l.Name)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *Keeper) forget(name string) {
+	k.mu.Lock()
+	delete(k.owned, name)
+	k.mu.Unlock()
+}
+
+/*
+====================
+Context
+====================
+*/
+
+type ctxKey struct{}
+
+// WithLease 将租约挂到 ctx 上，供下游写事务做 fencing 校验
+func WithLease(ctx context.Context, l *repository.Lease) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext 取出 ctx 上的租约
+func FromContext(ctx context.Context) *repository.Lease {
+	l, _ := ctx.Value(ctxKey{}).(*repository.Lease)
+	return l
+}
+
+type fenceKey struct{}
+
+// WithFence 附加一个只用于写事务 fencing 校验的租约（不参与续期），如 rewind 期间占用的 calculator 租约
+func WithFence(ctx context.Context, l *repository.Lease) context.Context {
+	prev := fencesFromContext(ctx)
+	return context.WithValue(ctx, fenceKey{}, append(prev, l))
+}
+
+// fencesFromContext 返回副本，调用方可以安全 append
+func fencesFromContext(ctx context.Context) []*repository.Lease {
+	prev, _ := ctx.Value(fenceKey{}).([]*repository.Lease)
+	return append([]*repository.Lease(nil), prev...)
+}
+
+/*
+====================
+Names
+====================
+*/
+
+// IndexerName indexer 的合约租约名
+func IndexerName(chainID int64, contract string) string {
+	return fmt.Sprintf("indexer:%d:%s", chainID, contract)
+}
+
+// CalculatorName calculator 的合约租约名
+func CalculatorName(chainID int64, contract string) string {
+	return fmt.Sprintf("calculator:%d:%s", chainID, contract)
+}
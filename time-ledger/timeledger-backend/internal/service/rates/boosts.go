@@ -0,0 +1,170 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+)
+
+var (
+	ErrInvalidBoost  = errors.New("invalid boost")
+	ErrBoostNotFound = errors.New("boost not found")
+	ErrBoostStarted  = errors.New("boost has already started and cannot be cancelled")
+)
+
+// boostMultiplierScale point_boost.multiplier 的小数位（decimal(20,8)）
+const boostMultiplierScale = 8
+
+// NewBoost 新增账户倍率的请求
+type NewBoost struct {
+	ChainID    int64
+	Contract   string
+	Account    string
+	Multiplier decimal.Decimal
+	StartTime  time.Time
+	EndTime    *time.Time // nil = 长期有效
+	Reason     string
+
+	// 允许开始时间早于当前时间（触发积分重算）
+	Retroactive bool
+}
+
+// ListBoosts 合约的 boost 列表，account 为空时返回全部账户
+func (s *Service) ListBoosts(
+	ctx context.Context,
+	chainID int64,
+	contract, account string,
+) ([]models.PointBoost, error) {
+
+	q := s.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=?", chainID, contract)
+	if account != "" {
+		q = q.Where("account=?", account)
+	}
+
+	var rows []models.PointBoost
+	err := q.Order("start_time ASC, id ASC").Find(&rows).Error
+	return rows, err
+}
+
+// AddBoost 校验后新增账户倍率
+func (s *Service) AddBoost(ctx context.Context, req NewBoost) (*models.PointBoost, error) {
+	if req.Account == "" {
+		return nil, fmt.Errorf("%w: missing account", ErrInvalidBoost)
+	}
+	if !req.Multiplier.IsPositive() {
+		return nil, fmt.Errorf("%w: multiplier must be > 0", ErrInvalidBoost)
+	}
+	// 超出列精度会被数据库静默截断，导致记录与请求不一致
+	if !req.Multiplier.Equal(req.Multiplier.Truncate(boostMultiplierScale)) {
+		return nil, fmt.Errorf("%w: multiplier supports at most %d decimal places", ErrInvalidBoost, boostMultiplierScale)
+	}
+	if req.Multiplier.GreaterThanOrEqual(decimal.New(1, 20-boostMultiplierScale)) {
+		return nil, fmt.Errorf("%w: multiplier is too large", ErrInvalidBoost)
+	}
+	if req.StartTime.IsZero() {
+		return nil, fmt.Errorf("%w: missing start_time", ErrInvalidBoost)
+	}
+	if req.EndTime != nil && !req.EndTime.After(req.StartTime) {
+		return nil, fmt.Errorf("%w: end_time must be after start_time", ErrInvalidBoost)
+	}
+
+	start := req.StartTime.UTC()
+	if !start.After(time.Now().UTC()) && !req.Retroactive {
+		return nil, ErrBackdated
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.SysContract{}).
+		Where("chain_id=? AND address=?", req.ChainID, req.Contract).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrContractNotFound
+	}
+
+	boost := &models.PointBoost{
+		ChainID:         req.ChainID,
+		ContractAddress: req.Contract,
+		Account:         req.Account,
+		Multiplier:      req.Multiplier.String(),
+		StartTime:       start,
+		Reason:          req.Reason,
+		CreatedAt:       time.Now().UTC(),
+	}
+	if req.EndTime != nil {
+		end := req.EndTime.UTC()
+		boost.EndTime = &end
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(boost).Error; err != nil {
+			return err
+		}
+
+		// 开始时间早于该账户已结算时间时，只重算该账户
+		_, err := repository.EnqueueRecompute(
+			ctx,
+			tx,
+			boost.ChainID,
+			boost.ContractAddress,
+			boost.Account,
+			boost.StartTime,
+			repository.RecomputeReasonBoost,
+			0,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return boost, nil
+}
+
+// CancelBoost 取消尚未开始的账户倍率
+func (s *Service) CancelBoost(ctx context.Context, id uint64) (*models.PointBoost, error) {
+	var boost models.PointBoost
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id=?", id).First(&boost).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBoostNotFound
+			}
+			return err
+		}
+
+		if !boost.StartTime.After(time.Now().UTC()) {
+			return ErrBoostStarted
+		}
+
+		if err := tx.Delete(&models.PointBoost{}, boost.ID).Error; err != nil {
+			return err
+		}
+
+		// 与 Cancel 相同：防御与计算并发的边界情况（只涉及该账户）
+		_, err := repository.EnqueueRecompute(
+			ctx,
+			tx,
+			boost.ChainID,
+			boost.ContractAddress,
+			boost.Account,
+			boost.StartTime,
+			repository.RecomputeReasonBoost,
+			0,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &boost, nil
+}
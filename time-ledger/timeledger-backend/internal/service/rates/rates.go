@@ -0,0 +1,273 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/formula"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/models"
+	"github.com/Atom257/web3-labs/timeledger-backend/internal/repository"
+)
+
+/*
+Rates
+-----
+- 积分规则管理：查看完整时间表、新增未来生效的规则、取消尚未生效的规则
+- 校验在这里做，repository 只负责读写
+- 生效时间早于当前时间视为回溯，需显式 retroactive：写入后由 repository 创建 recompute_job，calculator 重算已发放积分
+- 公式（rate_formula）按 (合约, name) 追加版本，已有版本不修改；规则通过 formula_id 引用具体版本
+- 账户倍率（point_boost）与规则相同：默认只能新增 / 取消未开始的，回溯需显式 retroactive，且只重算该账户
+*/
+
+var (
+	ErrInvalidRate       = errors.New("invalid rate")
+	ErrContractNotFound  = errors.New("contract not configured")
+	ErrDuplicateTime     = errors.New("a rate with the same effective_time already exists")
+	ErrBackdated         = errors.New("effective_time is in the past; set retroactive to recompute issued points")
+	ErrRateNotFound      = errors.New("rate not found")
+	ErrAlreadyEffective  = errors.New("rate is already effective and cannot be cancelled")
+	ErrInitialRateLocked = errors.New("the earliest rate cannot be cancelled")
+	ErrInvalidFormula    = errors.New("invalid formula")
+	ErrFormulaNotFound   = errors.New("formula not found")
+)
+
+// NewRate 新增积分规则的请求
+type NewRate struct {
+	ChainID         int64
+	Contract        string
+	RateNumerator   int64
+	RateDenominator int64
+	EffectiveTime   time.Time
+	FormulaID       uint64 // 可选，0 = 线性
+
+	// 允许生效时间早于当前时间（回溯修正，触发积分重算）
+	Retroactive bool
+}
+
+type Service struct {
+	db   *gorm.DB
+	repo repository.PointRateRepository
+}
+
+func New(db *gorm.DB) *Service {
+	return &Service{
+		db:   db,
+		repo: repository.NewPointRateRepo(db),
+	}
+}
+
+// List 合约的完整积分规则时间表（按生效时间升序）
+func (s *Service) List(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+) ([]models.PointRate, error) {
+	return s.repo.ListAll(ctx, chainID, contract)
+}
+
+// Add 校验后新增积分规则
+func (s *Service) Add(ctx context.Context, req NewRate) (*models.PointRate, error) {
+	if req.RateDenominator <= 0 {
+		return nil, fmt.Errorf("%w: rate_denominator must be > 0", ErrInvalidRate)
+	}
+	if req.RateNumerator < 0 {
+		return nil, fmt.Errorf("%w: rate_numerator must be >= 0", ErrInvalidRate)
+	}
+	if req.EffectiveTime.IsZero() {
+		return nil, fmt.Errorf("%w: missing effective_time", ErrInvalidRate)
+	}
+
+	at := req.EffectiveTime.UTC()
+	if !at.After(time.Now().UTC()) && !req.Retroactive {
+		return nil, ErrBackdated
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.SysContract{}).
+		Where("chain_id=? AND address=?", req.ChainID, req.Contract).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrContractNotFound
+	}
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.PointRate{}).
+		Where(
+			"chain_id=? AND contract_address=? AND effective_time=?",
+			req.ChainID, req.Contract, at,
+		).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrDuplicateTime
+	}
+
+	if req.FormulaID != 0 {
+		if err := s.db.WithContext(ctx).
+			Model(&models.RateFormula{}).
+			Where(
+				"id=? AND chain_id=? AND contract_address=?",
+				req.FormulaID, req.ChainID, req.Contract,
+			).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrFormulaNotFound
+		}
+	}
+
+	rate := &models.PointRate{
+		ChainID:         req.ChainID,
+		ContractAddress: req.Contract,
+		RateNumerator:   req.RateNumerator,
+		RateDenominator: req.RateDenominator,
+		FormulaID:       req.FormulaID,
+		EffectiveTime:   at,
+		CreatedAt:       time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// Cancel 取消尚未生效的积分规则
+func (s *Service) Cancel(ctx context.Context, id uint64) (*models.PointRate, error) {
+	var rate models.PointRate
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id=?", id).First(&rate).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRateNotFound
+			}
+			return err
+		}
+
+		if !rate.EffectiveTime.After(time.Now().UTC()) {
+			return ErrAlreadyEffective
+		}
+
+		// 最早的规则是所有计算的基准，不能删除
+		var earlier int64
+		if err := tx.Model(&models.PointRate{}).
+			Where(
+				"chain_id=? AND contract_address=? AND effective_time < ?",
+				rate.ChainID, rate.ContractAddress, rate.EffectiveTime,
+			).
+			Count(&earlier).Error; err != nil {
+			return err
+		}
+		if earlier == 0 {
+			return ErrInitialRateLocked
+		}
+
+		if err := tx.Delete(&models.PointRate{}, rate.ID).Error; err != nil {
+			return err
+		}
+
+		// 正常情况下未生效的规则不影响已发放积分，这里只防御与计算并发的边界情况
+		_, err := repository.EnqueueRecompute(
+			ctx,
+			tx,
+			rate.ChainID,
+			rate.ContractAddress,
+			"",
+			rate.EffectiveTime,
+			repository.RecomputeReasonRateCancel,
+			rate.ID,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// ListFormulas 合约的全部公式版本（按名称、版本升序）
+func (s *Service) ListFormulas(
+	ctx context.Context,
+	chainID int64,
+	contract string,
+) ([]models.RateFormula, error) {
+
+	var rows []models.RateFormula
+	err := s.db.WithContext(ctx).
+		Where("chain_id=? AND contract_address=?", chainID, contract).
+		Order("name ASC, version ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
+// AddFormula 校验公式定义后追加为 name 的新版本
+func (s *Service) AddFormula(
+	ctx context.Context,
+	chainID int64,
+	contract, name string,
+	definition json.RawMessage,
+) (*models.RateFormula, error) {
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing name", ErrInvalidFormula)
+	}
+
+	f, err := formula.Parse(definition)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormula, err)
+	}
+	// 以规范化后的 JSON 存储
+	normalized, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.SysContract{}).
+		Where("chain_id=? AND address=?", chainID, contract).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrContractNotFound
+	}
+
+	row := &models.RateFormula{
+		ChainID:         chainID,
+		ContractAddress: contract,
+		Name:            name,
+		Definition:      normalized,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	// 并发追加同名版本时由唯一索引兜底
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var latest struct{ Version *int }
+		if err := tx.Model(&models.RateFormula{}).
+			Select("MAX(version) AS version").
+			Where("chain_id=? AND contract_address=? AND name=?", chainID, contract, name).
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+
+		row.Version = 1
+		if latest.Version != nil {
+			row.Version = *latest.Version + 1
+		}
+		return tx.Create(row).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}